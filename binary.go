@@ -0,0 +1,237 @@
+package smap
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "time"
+)
+
+// binaryMagic 二进制格式的文件头标识
+var binaryMagic = [4]byte{'S', 'M', 'A', 'P'}
+
+// binaryVersion 当前的二进制格式版本
+const binaryVersion = 1
+
+// ErrCorrupted 二进制数据损坏或格式不正确
+var ErrCorrupted = errors.New("smap: corrupted data")
+
+// binaryEntry 二进制格式中的一个数据项，Expire为过期时间(UnixNano)，0表示永不过期
+// Version为数据项的版本号，只在OpenMap加载快照时恢复，ReadFrom读取的数据重新分配版本号
+type binaryEntry[K comparable, V any] struct {
+    Key     K
+    Value   V
+    Expire  int64
+    Version uint64
+}
+
+func init() {
+    RegisterType([]interface{}{})
+    RegisterType(map[string]interface{}{})
+    RegisterType(map[interface{}]interface{}{})
+    RegisterType(time.Time{})
+    RegisterType(time.Duration(0))
+}
+
+// RegisterType 注册可以出现在interface{}类型的key或value中的具体类型，基础类型已默认注册
+// 与gob.Register相同，需在WriteTo和ReadFrom之前调用，写入和读取两端注册的类型需一致
+func RegisterType(value interface{}) {
+    gob.Register(value)
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
+
+// countingReader 统计读取的字节数，err 记录底层读取遇到的非EOF错误
+type countingReader struct {
+    r   io.Reader
+    n   int64
+    err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    c.n += int64(n)
+    if err != nil && err != io.EOF {
+        c.err = err
+    }
+    return n, err
+}
+
+// ReadByte 实现io.ByteReader，供binary.ReadUvarint使用
+func (c *countingReader) ReadByte() (byte, error) {
+    var b [1]byte
+    _, err := io.ReadFull(c, b[:])
+    return b[0], err
+}
+
+// WriteTo 以二进制格式写入所有数据，实现io.WriterTo
+// 格式为文件头（标识、版本、数据数量及其CRC32）加逐个数据项的帧（长度、gob编码的数据及其CRC32）
+// 写入期间使用快照，不会复制数据也不会阻塞写操作；interface{}中的自定义类型需先调用RegisterType注册
+func (a *Map[K, V]) WriteTo(w io.Writer) (n int64, err error) {
+    snap := a.Snapshot()
+    defer snap.Release()
+    return snap.WriteTo(w)
+}
+
+// WriteTo 以二进制格式写入快照中的数据，实现io.WriterTo，格式同Map.WriteTo
+func (snap *Snapshot[K, V]) WriteTo(w io.Writer) (n int64, err error) {
+    defer errorRecover(&err)
+    cw := &countingWriter{w: w}
+    bw := bufio.NewWriter(cw)
+
+    header := make([]byte, 0, 18)
+    header = append(header, binaryMagic[:]...)
+    header = append(header, binaryVersion, 0)
+    header = binary.BigEndian.AppendUint64(header, uint64(snap.Size()))
+    header = binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+    if _, err := bw.Write(header); err != nil {
+        return cw.n, err
+    }
+
+    var payload bytes.Buffer
+    enc := gob.NewEncoder(&payload)
+    frame := make([]byte, 0, 64)
+    for _, data := range snap.data {
+        for k, e := range data.all() {
+            if e.expired(snap.now) {
+                continue
+            }
+            payload.Reset()
+            if err := enc.Encode(binaryEntry[K, V]{Key: k, Value: e.value, Expire: e.expire, Version: e.version}); err != nil {
+                return cw.n, err
+            }
+            if frame, err = writeFrame(bw, frame, payload.Bytes()); err != nil {
+                return cw.n, err
+            }
+        }
+    }
+    err = bw.Flush()
+
+    return cw.n, err
+}
+
+// ReadFrom 读取WriteTo写入的二进制数据并写入map，实现io.ReaderFrom
+// 逐个数据项读取并写入，不会在内存中保留整份数据；已过期的数据项会被跳过
+// 数据损坏时返回ErrCorrupted，此前已读取的数据项仍会保留
+func (a *Map[K, V]) ReadFrom(r io.Reader) (n int64, err error) {
+    a.lazyInit()
+    return readBinary(r, a.restore)
+}
+
+// readBinary 读取二进制数据并对每个数据项调用fn
+func readBinary[K comparable, V any](r io.Reader, fn func(e binaryEntry[K, V]) error) (n int64, err error) {
+    defer errorRecover(&err)
+    cr := &countingReader{r: bufio.NewReader(r)}
+    header := make([]byte, 18)
+    if _, err := io.ReadFull(cr, header); err != nil {
+        return cr.n, corrupted(err)
+    }
+    if !bytes.Equal(header[:4], binaryMagic[:]) {
+        return cr.n, fmt.Errorf("%w: bad magic %q", ErrCorrupted, header[:4])
+    }
+    if header[4] != binaryVersion {
+        return cr.n, fmt.Errorf("smap: unsupported binary version %d", header[4])
+    }
+    if crc32.ChecksumIEEE(header[:14]) != binary.BigEndian.Uint32(header[14:]) {
+        return cr.n, fmt.Errorf("%w: header checksum mismatch", ErrCorrupted)
+    }
+    count := binary.BigEndian.Uint64(header[6:14])
+
+    var payload bytes.Buffer
+    dec := gob.NewDecoder(&payload)
+    for i := uint64(0); i < count; i++ {
+        if err := readFrame(cr, &payload); err != nil {
+            return cr.n, corrupted(err)
+        }
+        var e binaryEntry[K, V]
+        if err := dec.Decode(&e); err != nil {
+            return cr.n, err
+        }
+        if err := fn(e); err != nil {
+            return cr.n, err
+        }
+    }
+
+    return cr.n, nil
+}
+
+// writeFrame 写入一帧数据：长度、CRC32和数据本身，帧在一次Write中写入，frame为可复用的缓冲区
+func writeFrame(w io.Writer, frame []byte, payload []byte) ([]byte, error) {
+    frame = binary.AppendUvarint(frame[:0], uint64(len(payload)))
+    frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(payload))
+    frame = append(frame, payload...)
+    _, err := w.Write(frame)
+    return frame, err
+}
+
+// readFrame 读取writeFrame写入的一帧数据到payload
+// 恰好在帧边界遇到结尾时返回io.EOF，数据不完整或校验失败时返回ErrCorrupted，底层读取失败时返回原错误
+func readFrame(r *countingReader, payload *bytes.Buffer) error {
+    size, err := binary.ReadUvarint(r)
+    switch {
+    case err == io.EOF:
+        return io.EOF
+    case err != nil && r.err == nil:
+        return fmt.Errorf("%w: bad frame length: %v", ErrCorrupted, err)
+    case err != nil:
+        return err
+    case size == 0:
+        // 写入的帧总是非空的，长度为0通常是崩溃后文件末尾留下的零字节
+        return fmt.Errorf("%w: empty frame", ErrCorrupted)
+    }
+    var sum [4]byte
+    if _, err := io.ReadFull(r, sum[:]); err != nil {
+        return corrupted(err)
+    }
+    payload.Reset()
+    if _, err := io.CopyN(payload, r, int64(size)); err != nil {
+        return corrupted(err)
+    }
+    if crc32.ChecksumIEEE(payload.Bytes()) != binary.BigEndian.Uint32(sum[:]) {
+        return fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+    }
+    return nil
+}
+
+// corrupted 把读取时遇到的EOF转换为ErrCorrupted
+func corrupted(err error) error {
+    if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+        return fmt.Errorf("%w: unexpected end of data", ErrCorrupted)
+    }
+    return err
+}
+
+// restore 写入读取到的数据项，保留原有的过期时间
+func (a *Map[K, V]) restore(e binaryEntry[K, V]) error {
+    if e.Expire == 0 {
+        return a.Set(e.Key, e.Value)
+    }
+    ttl := time.Until(time.Unix(0, e.Expire))
+    if ttl <= 0 {
+        return nil
+    }
+    return a.SetWithTTL(e.Key, e.Value, ttl)
+}
+
+// ReadFrom 同Map.ReadFrom，支持读取到零值的MapStrAny
+func (a *MapStrAny) ReadFrom(r io.Reader) (n int64, err error) {
+    if a.strAnyMap == nil {
+        a.strAnyMap = NewMap[string, interface{}]()
+    }
+    return a.strAnyMap.ReadFrom(r)
+}
@@ -0,0 +1,153 @@
+package smap
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "reflect"
+    "strconv"
+    "testing"
+    "time"
+)
+
+type binaryPoint struct {
+    X, Y int
+}
+
+func init() {
+    RegisterType(binaryPoint{})
+}
+
+func TestMap_WriteTo(t *testing.T) {
+    tests := []struct {
+        name string
+        data map[interface{}]interface{}
+        opts []Option
+    }{
+        {name: "empty", data: map[interface{}]interface{}{}},
+        {name: "mixed", data: map[interface{}]interface{}{
+            "a": 1, 2: "b", 1.5: []interface{}{"x", 2}, "nested": map[string]interface{}{"k": true},
+            "point": binaryPoint{1, 2}, "dur": time.Second,
+        }},
+        {name: "sharded", data: map[interface{}]interface{}{"a": 1, "b": 2, "c": 3}, opts: []Option{WithShards(4)}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapAnyWith(tt.opts...)
+            for k, v := range tt.data {
+                m.Set(k, v)
+            }
+            var buf bytes.Buffer
+            n, err := m.WriteTo(&buf)
+            if err != nil || n != int64(buf.Len()) {
+                t.Fatalf("WriteTo() = %d, %v, buffer %d", n, err, buf.Len())
+            }
+            got := NewMapAny(true)
+            size := buf.Len()
+            if n, err := got.ReadFrom(&buf); err != nil || n != int64(size) {
+                t.Fatalf("ReadFrom() = %d, %v, want %d", n, err, size)
+            }
+            if !reflect.DeepEqual(got.All(), tt.data) {
+                t.Errorf("ReadFrom() = %v, want %v", got.All(), tt.data)
+            }
+        })
+    }
+}
+
+func TestSnapshot_WriteTo(t *testing.T) {
+    m := NewMapWith[string, int](WithShards(2))
+    for i := 0; i < 100; i++ {
+        m.Set(strconv.Itoa(i), i)
+    }
+    want := m.All()
+    snap := m.Snapshot()
+    defer snap.Release()
+    for i := 0; i < 100; i += 2 {
+        m.Set(strconv.Itoa(i), -i)
+        m.Remove(strconv.Itoa(i + 1))
+    }
+    var buf bytes.Buffer
+    if _, err := snap.WriteTo(&buf); err != nil {
+        t.Fatalf("WriteTo() error = %v", err)
+    }
+    got := NewMap[string, int]()
+    if _, err := got.ReadFrom(&buf); err != nil {
+        t.Fatalf("ReadFrom() error = %v", err)
+    }
+    if !reflect.DeepEqual(got.All(), want) {
+        t.Errorf("WriteTo() wrote writes made after the snapshot: %v", got.All())
+    }
+}
+
+func TestMapStrAny_ReadFrom(t *testing.T) {
+    m := NewMapStrAny()
+    m.Set("keep", 1)
+    m.SetWithTTL("ttl", 2, time.Hour)
+    m.SetWithTTL("expired", 3, time.Nanosecond)
+    time.Sleep(time.Millisecond)
+    var buf bytes.Buffer
+    m.WriteTo(&buf)
+
+    var got MapStrAny
+    if _, err := got.ReadFrom(&buf); err != nil {
+        t.Fatalf("ReadFrom() error = %v", err)
+    }
+    if want := map[string]interface{}{"keep": 1, "ttl": 2}; !reflect.DeepEqual(got.All(), want) {
+        t.Errorf("ReadFrom() = %v, want %v", got.All(), want)
+    }
+    if ttl, _, _ := got.TTL("ttl"); ttl <= 0 || ttl > time.Hour {
+        t.Errorf("ReadFrom() ttl = %v, want (0, 1h]", ttl)
+    }
+}
+
+func TestMap_ReadFromCorrupted(t *testing.T) {
+    m := NewMap[string, int]()
+    m.Set("a", 1)
+    m.Set("b", 2)
+    var buf bytes.Buffer
+    m.WriteTo(&buf)
+    data := buf.Bytes()
+    tests := []struct {
+        name    string
+        data    []byte
+        wantErr error
+    }{
+        {name: "empty", data: nil, wantErr: ErrCorrupted},
+        {name: "bad magic", data: append([]byte("XMAP"), data[4:]...), wantErr: ErrCorrupted},
+        {name: "bad header checksum", data: func() []byte {
+            d := bytes.Clone(data)
+            d[13]++
+            return d
+        }(), wantErr: ErrCorrupted},
+        {name: "truncated", data: data[:len(data)-3], wantErr: ErrCorrupted},
+        {name: "flipped payload", data: func() []byte {
+            d := bytes.Clone(data)
+            d[len(d)-2] ^= 0xff
+            return d
+        }(), wantErr: ErrCorrupted},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := NewMap[string, int]()
+            if _, err := got.ReadFrom(bytes.NewReader(tt.data)); !errors.Is(err, tt.wantErr) {
+                t.Errorf("ReadFrom() error = %v, want %v", err, tt.wantErr)
+            }
+        })
+    }
+    version := bytes.Clone(data)
+    version[4] = 9
+    if _, err := NewMap[string, int]().ReadFrom(bytes.NewReader(version)); err == nil || errors.Is(err, ErrCorrupted) {
+        t.Errorf("ReadFrom() unknown version error = %v", err)
+    }
+}
+
+func BenchmarkMap_WriteTo(b *testing.B) {
+    m := NewMap[int, string](true)
+    for i := 0; i < 10000; i++ {
+        m.Set(i, "value")
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        m.WriteTo(io.Discard)
+    }
+}
@@ -0,0 +1,94 @@
+package smap
+
+// GetOrSet 获取key对应的value，key不存在时写入value
+// loaded为true表示key已存在，actual为已存在的value；否则actual为写入的value
+func (a *Map[K, V]) GetOrSet(key K, value V) (actual V, loaded bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if e, ok := lookup(s.current(), key, a.now()); ok {
+        a.touchLocked(s, key)
+        return e.value, true, nil
+    }
+    if err := a.storeLocked(s, key, a.newEntry(value)); err != nil {
+        return actual, false, err
+    }
+
+    return value, false, err
+}
+
+// LoadAndDelete 删除key并返回删除前的value，loaded表示key是否存在
+func (a *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, ok := lookup(s.current(), key, a.now())
+    if !ok {
+        return value, false, nil
+    }
+    if err := a.removeLocked(s, key); err != nil {
+        return value, false, err
+    }
+
+    return e.value, true, err
+}
+
+// Pop 同LoadAndDelete
+func (a *Map[K, V]) Pop(key K) (value V, loaded bool, err error) {
+    return a.LoadAndDelete(key)
+}
+
+// Swap 写入新value并返回旧value，loaded表示key原先是否存在
+func (a *Map[K, V]) Swap(key K, value V) (previous V, loaded bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, loaded := lookup(s.current(), key, a.now())
+    if loaded {
+        previous = e.value
+    }
+    if err := a.storeLocked(s, key, a.newEntry(value)); err != nil {
+        return previous, loaded, err
+    }
+
+    return previous, loaded, err
+}
+
+// CompareAndSwap 当key的当前value等于old时写入new
+// value的动态类型不可比较时返回error
+func (a *Map[K, V]) CompareAndSwap(key K, old, new V) (swapped bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, ok := lookup(s.current(), key, a.now())
+    if !ok || any(e.value) != any(old) {
+        return false, nil
+    }
+    if err := a.storeLocked(s, key, a.newEntry(new)); err != nil {
+        return false, err
+    }
+
+    return true, err
+}
+
+// CompareAndDelete 当key的当前value等于old时删除key
+// value的动态类型不可比较时返回error
+func (a *Map[K, V]) CompareAndDelete(key K, old V) (deleted bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, ok := lookup(s.current(), key, a.now())
+    if !ok || any(e.value) != any(old) {
+        return false, nil
+    }
+    if err := a.removeLocked(s, key); err != nil {
+        return false, err
+    }
+
+    return true, err
+}
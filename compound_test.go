@@ -0,0 +1,173 @@
+package smap
+
+import (
+    "reflect"
+    "sync"
+    "sync/atomic"
+    "testing"
+)
+
+func TestMap_GetOrSet(t *testing.T) {
+    data := map[interface{}]interface{}{"a": 1}
+    tests := []struct {
+        name       string
+        a          *MapAny
+        key        interface{}
+        value      interface{}
+        wantActual interface{}
+        wantLoaded bool
+        wantErr    bool
+    }{
+        {name: "unsafe loaded", a: getMapAny(data), key: "a", value: 2, wantActual: 1, wantLoaded: true},
+        {name: "unsafe stored", a: getMapAny(data), key: "b", value: 2, wantActual: 2, wantLoaded: false},
+        {name: "unsafe error", a: getMapAny(data), key: []string{"a"}, value: 2, wantActual: nil, wantErr: true},
+        {name: "safe loaded", a: getMapAny(data, true), key: "a", value: 2, wantActual: 1, wantLoaded: true},
+        {name: "safe stored", a: getMapAny(data, true), key: "b", value: 2, wantActual: 2, wantLoaded: false},
+        {name: "sharded stored", a: getMapAnySharded(data), key: "b", value: 2, wantActual: 2, wantLoaded: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            actual, loaded, err := tt.a.GetOrSet(tt.key, tt.value)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("GetOrSet() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if !reflect.DeepEqual(actual, tt.wantActual) || loaded != tt.wantLoaded {
+                t.Errorf("GetOrSet() = %v, %v, want %v, %v", actual, loaded, tt.wantActual, tt.wantLoaded)
+            }
+            if v, _, _ := tt.a.Get(tt.key); !tt.wantErr && !reflect.DeepEqual(v, tt.wantActual) {
+                t.Errorf("Get() = %v, want %v", v, tt.wantActual)
+            }
+        })
+    }
+}
+
+func TestMap_LoadAndDelete(t *testing.T) {
+    tests := []struct {
+        name       string
+        a          *MapStrAny
+        key        string
+        wantValue  interface{}
+        wantLoaded bool
+    }{
+        {name: "unsafe loaded", a: getMapStrAny(map[string]interface{}{"a": 1}), key: "a", wantValue: 1, wantLoaded: true},
+        {name: "unsafe missing", a: getMapStrAny(map[string]interface{}{"a": 1}), key: "b", wantValue: nil, wantLoaded: false},
+        {name: "safe loaded", a: getMapStrAny(map[string]interface{}{"a": 1}, true), key: "a", wantValue: 1, wantLoaded: true},
+        {name: "safe missing", a: getMapStrAny(map[string]interface{}{"a": 1}, true), key: "b", wantValue: nil, wantLoaded: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            value, loaded, err := tt.a.LoadAndDelete(tt.key)
+            if err != nil || !reflect.DeepEqual(value, tt.wantValue) || loaded != tt.wantLoaded {
+                t.Errorf("LoadAndDelete() = %v, %v, %v, want %v, %v", value, loaded, err, tt.wantValue, tt.wantLoaded)
+            }
+            if tt.a.Has(tt.key) {
+                t.Errorf("LoadAndDelete() key %v not removed", tt.key)
+            }
+        })
+    }
+}
+
+func TestMap_Swap(t *testing.T) {
+    tests := []struct {
+        name         string
+        a            *MapStrAny
+        key          string
+        value        interface{}
+        wantPrevious interface{}
+        wantLoaded   bool
+    }{
+        {name: "unsafe loaded", a: getMapStrAny(map[string]interface{}{"a": 1}), key: "a", value: 2, wantPrevious: 1, wantLoaded: true},
+        {name: "unsafe missing", a: getMapStrAny(nil), key: "a", value: 2, wantPrevious: nil, wantLoaded: false},
+        {name: "safe loaded", a: getMapStrAny(map[string]interface{}{"a": 1}, true), key: "a", value: 2, wantPrevious: 1, wantLoaded: true},
+        {name: "safe missing", a: getMapStrAny(nil, true), key: "a", value: 2, wantPrevious: nil, wantLoaded: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            previous, loaded, err := tt.a.Swap(tt.key, tt.value)
+            if err != nil || !reflect.DeepEqual(previous, tt.wantPrevious) || loaded != tt.wantLoaded {
+                t.Errorf("Swap() = %v, %v, %v, want %v, %v", previous, loaded, err, tt.wantPrevious, tt.wantLoaded)
+            }
+            if v, _, _ := tt.a.Get(tt.key); v != tt.value {
+                t.Errorf("Get() = %v, want %v", v, tt.value)
+            }
+        })
+    }
+}
+
+func TestMap_CompareAndSwap(t *testing.T) {
+    data := map[string]interface{}{"a": 1, "s": []int{1}}
+    tests := []struct {
+        name        string
+        a           *MapStrAny
+        key         string
+        old, new    interface{}
+        wantSwapped bool
+        wantErr     bool
+        wantValue   interface{}
+    }{
+        {name: "unsafe swapped", a: getMapStrAny(data), key: "a", old: 1, new: 2, wantSwapped: true, wantValue: 2},
+        {name: "unsafe mismatch", a: getMapStrAny(data), key: "a", old: 3, new: 2, wantSwapped: false, wantValue: 1},
+        {name: "unsafe missing", a: getMapStrAny(data), key: "b", old: 1, new: 2, wantSwapped: false, wantValue: nil},
+        {name: "unsafe uncomparable", a: getMapStrAny(data), key: "s", old: []int{1}, new: 2, wantErr: true, wantValue: []int{1}},
+        {name: "safe swapped", a: getMapStrAny(data, true), key: "a", old: 1, new: 2, wantSwapped: true, wantValue: 2},
+        {name: "safe mismatch", a: getMapStrAny(data, true), key: "a", old: 3, new: 2, wantSwapped: false, wantValue: 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            swapped, err := tt.a.CompareAndSwap(tt.key, tt.old, tt.new)
+            if (err != nil) != tt.wantErr || swapped != tt.wantSwapped {
+                t.Errorf("CompareAndSwap() = %v, %v, want %v, wantErr %v", swapped, err, tt.wantSwapped, tt.wantErr)
+            }
+            if v, _, _ := tt.a.Get(tt.key); !reflect.DeepEqual(v, tt.wantValue) {
+                t.Errorf("Get() = %v, want %v", v, tt.wantValue)
+            }
+        })
+    }
+}
+
+func TestMap_CompareAndDelete(t *testing.T) {
+    data := map[string]interface{}{"a": 1}
+    tests := []struct {
+        name        string
+        a           *MapStrAny
+        key         string
+        old         interface{}
+        wantDeleted bool
+    }{
+        {name: "unsafe deleted", a: getMapStrAny(data), key: "a", old: 1, wantDeleted: true},
+        {name: "unsafe mismatch", a: getMapStrAny(data), key: "a", old: 2, wantDeleted: false},
+        {name: "safe deleted", a: getMapStrAny(data, true), key: "a", old: 1, wantDeleted: true},
+        {name: "safe mismatch", a: getMapStrAny(data, true), key: "a", old: 2, wantDeleted: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            deleted, err := tt.a.CompareAndDelete(tt.key, tt.old)
+            if err != nil || deleted != tt.wantDeleted {
+                t.Errorf("CompareAndDelete() = %v, %v, want %v", deleted, err, tt.wantDeleted)
+            }
+            if tt.a.Has(tt.key) == tt.wantDeleted {
+                t.Errorf("Has() = %v after CompareAndDelete() = %v", tt.a.Has(tt.key), deleted)
+            }
+        })
+    }
+}
+
+func TestMap_GetOrSet_concurrent(t *testing.T) {
+    m := NewMapWith[string, int](WithShards(4))
+    var stored int32
+    var wg sync.WaitGroup
+    for i := 0; i < 16; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            if _, loaded, _ := m.GetOrSet("key", i); !loaded {
+                atomic.AddInt32(&stored, 1)
+            }
+        }(i)
+    }
+    wg.Wait()
+    if stored != 1 {
+        t.Errorf("GetOrSet() stored %v times, want 1", stored)
+    }
+}
@@ -0,0 +1,63 @@
+package smap
+
+// Compute 在持有锁的情况下根据旧值计算新值
+// fn的old为当前value，exists表示key是否存在；fn返回keep为true时写入new，否则删除key
+// 返回计算后的value及key是否存在，fn内的panic会以error形式返回且不修改数据
+// fn执行期间持有key所在分片的锁，不能在fn内再调用当前map的方法
+func (a *Map[K, V]) Compute(key K, fn func(old V, exists bool) (new V, keep bool)) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, exists := lookup(s.current(), key, a.now())
+    return a.compute(s, key, e.value, exists, fn)
+}
+
+// ComputeIfAbsent key不存在时在持有锁的情况下计算value
+// fn返回keep为true时写入value，key已存在时直接返回当前value
+func (a *Map[K, V]) ComputeIfAbsent(key K, fn func() (value V, keep bool)) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if e, exists := lookup(s.current(), key, a.now()); exists {
+        a.touchLocked(s, key)
+        return e.value, true, nil
+    }
+    return a.compute(s, key, val, false, func(V, bool) (V, bool) {
+        return fn()
+    })
+}
+
+// ComputeIfPresent key存在时在持有锁的情况下根据旧值计算新值
+// fn返回keep为true时写入new，否则删除key；key不存在时不调用fn
+func (a *Map[K, V]) ComputeIfPresent(key K, fn func(old V) (new V, keep bool)) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, exists := lookup(s.current(), key, a.now())
+    if !exists {
+        return val, false, nil
+    }
+    return a.compute(s, key, e.value, true, func(old V, _ bool) (V, bool) {
+        return fn(old)
+    })
+}
+
+// compute 执行fn并提交结果，需在持有分片写锁时调用
+func (a *Map[K, V]) compute(s *shard[K, V], key K, old V, exists bool, fn func(V, bool) (V, bool)) (val V, ok bool, err error) {
+    nv, keep := fn(old, exists)
+    if keep {
+        if err := a.storeLocked(s, key, a.newEntry(nv)); err != nil {
+            return val, exists, err
+        }
+        return nv, true, nil
+    }
+    if exists {
+        if err := a.removeLocked(s, key); err != nil {
+            return old, true, err
+        }
+    }
+    return val, false, nil
+}
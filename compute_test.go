@@ -0,0 +1,146 @@
+package smap
+
+import (
+    "reflect"
+    "sync"
+    "testing"
+)
+
+func TestMap_Compute(t *testing.T) {
+    appendFn := func(old interface{}, exists bool) (interface{}, bool) {
+        if !exists {
+            return []int{1}, true
+        }
+        return append(old.([]int), 1), true
+    }
+    deleteFn := func(old interface{}, exists bool) (interface{}, bool) {
+        return nil, false
+    }
+    panicFn := func(old interface{}, exists bool) (interface{}, bool) {
+        panic("compute failed")
+    }
+    tests := []struct {
+        name    string
+        a       *MapStrAny
+        fn      func(old interface{}, exists bool) (interface{}, bool)
+        wantVal interface{}
+        wantOk  bool
+        wantErr bool
+    }{
+        {name: "unsafe absent", a: getMapStrAny(nil), fn: appendFn, wantVal: []int{1}, wantOk: true},
+        {name: "unsafe present", a: getMapStrAny(map[string]interface{}{"k": []int{1}}), fn: appendFn, wantVal: []int{1, 1}, wantOk: true},
+        {name: "unsafe delete", a: getMapStrAny(map[string]interface{}{"k": []int{1}}), fn: deleteFn, wantVal: nil, wantOk: false},
+        {name: "unsafe panic", a: getMapStrAny(map[string]interface{}{"k": []int{1}}), fn: panicFn, wantVal: nil, wantOk: false, wantErr: true},
+        {name: "safe absent", a: getMapStrAny(nil, true), fn: appendFn, wantVal: []int{1}, wantOk: true},
+        {name: "safe present", a: getMapStrAny(map[string]interface{}{"k": []int{1}}, true), fn: appendFn, wantVal: []int{1, 1}, wantOk: true},
+        {name: "safe delete", a: getMapStrAny(map[string]interface{}{"k": []int{1}}, true), fn: deleteFn, wantVal: nil, wantOk: false},
+        {name: "safe panic", a: getMapStrAny(map[string]interface{}{"k": []int{1}}, true), fn: panicFn, wantVal: nil, wantOk: false, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            before, _, _ := tt.a.Get("k")
+            val, ok, err := tt.a.Compute("k", tt.fn)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("Compute() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if !reflect.DeepEqual(val, tt.wantVal) || ok != tt.wantOk {
+                t.Errorf("Compute() = %v, %v, want %v, %v", val, ok, tt.wantVal, tt.wantOk)
+            }
+            got, _, _ := tt.a.Get("k")
+            if tt.wantErr && !reflect.DeepEqual(got, before) {
+                t.Errorf("Compute() modified value on panic: %v", got)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.wantVal) {
+                t.Errorf("Get() = %v, want %v", got, tt.wantVal)
+            }
+            if tt.a.Size() > 1 {
+                t.Errorf("Size() = %v", tt.a.Size())
+            }
+        })
+    }
+}
+
+func TestMap_ComputeIfAbsent(t *testing.T) {
+    tests := []struct {
+        name     string
+        a        *MapAny
+        keep     bool
+        wantVal  interface{}
+        wantOk   bool
+        wantCall bool
+    }{
+        {name: "unsafe absent", a: getMapAny(nil), keep: true, wantVal: 2, wantOk: true, wantCall: true},
+        {name: "unsafe absent not keep", a: getMapAny(nil), keep: false, wantVal: nil, wantOk: false, wantCall: true},
+        {name: "unsafe present", a: getMapAny(map[interface{}]interface{}{"k": 1}), keep: true, wantVal: 1, wantOk: true, wantCall: false},
+        {name: "safe absent", a: getMapAny(nil, true), keep: true, wantVal: 2, wantOk: true, wantCall: true},
+        {name: "safe present", a: getMapAny(map[interface{}]interface{}{"k": 1}, true), keep: true, wantVal: 1, wantOk: true, wantCall: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            called := false
+            val, ok, err := tt.a.ComputeIfAbsent("k", func() (interface{}, bool) {
+                called = true
+                return 2, tt.keep
+            })
+            if err != nil || val != tt.wantVal || ok != tt.wantOk || called != tt.wantCall {
+                t.Errorf("ComputeIfAbsent() = %v, %v, %v, called %v, want %v, %v, called %v", val, ok, err, called, tt.wantVal, tt.wantOk, tt.wantCall)
+            }
+            if tt.a.Has("k") != tt.wantOk {
+                t.Errorf("Has() = %v, want %v", tt.a.Has("k"), tt.wantOk)
+            }
+        })
+    }
+}
+
+func TestMap_ComputeIfPresent(t *testing.T) {
+    tests := []struct {
+        name     string
+        a        *MapAny
+        keep     bool
+        wantVal  interface{}
+        wantOk   bool
+        wantCall bool
+    }{
+        {name: "unsafe absent", a: getMapAny(nil), keep: true, wantVal: nil, wantOk: false, wantCall: false},
+        {name: "unsafe present", a: getMapAny(map[interface{}]interface{}{"k": 1}), keep: true, wantVal: 2, wantOk: true, wantCall: true},
+        {name: "unsafe present delete", a: getMapAny(map[interface{}]interface{}{"k": 1}), keep: false, wantVal: nil, wantOk: false, wantCall: true},
+        {name: "safe absent", a: getMapAny(nil, true), keep: true, wantVal: nil, wantOk: false, wantCall: false},
+        {name: "safe present", a: getMapAny(map[interface{}]interface{}{"k": 1}, true), keep: true, wantVal: 2, wantOk: true, wantCall: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            called := false
+            val, ok, err := tt.a.ComputeIfPresent("k", func(old interface{}) (interface{}, bool) {
+                called = true
+                return old.(int) + 1, tt.keep
+            })
+            if err != nil || val != tt.wantVal || ok != tt.wantOk || called != tt.wantCall {
+                t.Errorf("ComputeIfPresent() = %v, %v, %v, called %v, want %v, %v, called %v", val, ok, err, called, tt.wantVal, tt.wantOk, tt.wantCall)
+            }
+            if tt.a.Has("k") != tt.wantOk {
+                t.Errorf("Has() = %v, want %v", tt.a.Has("k"), tt.wantOk)
+            }
+        })
+    }
+}
+
+func TestMap_Compute_concurrent(t *testing.T) {
+    m := NewMapWith[string, int](WithShards(4))
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                m.Compute("counter", func(old int, exists bool) (int, bool) {
+                    return old + 1, true
+                })
+            }
+        }()
+    }
+    wg.Wait()
+    if v, _, _ := m.Get("counter"); v != 800 {
+        t.Errorf("Get() = %v, want %v", v, 800)
+    }
+}
@@ -0,0 +1,69 @@
+package smap
+
+import "iter"
+
+// entry map中存储的数据项
+type entry[V any] struct {
+    value V
+    // expire 过期时间(UnixNano)，0表示永不过期
+    expire int64
+    // cost 数据的成本，用于成本预算淘汰
+    cost int64
+    // version 数据项的版本号，每次写入时递增
+    version uint64
+}
+
+// expired 检查数据项在now时刻是否已过期
+func (e entry[V]) expired(now int64) bool {
+    return e.expire > 0 && now >= e.expire
+}
+
+// lookup 从l中查找未过期的数据项
+func lookup[K comparable, V any](l layer[K, V], key K, now int64) (entry[V], bool) {
+    e, ok := l.get(key)
+    if !ok || e.expired(now) {
+        return e, false
+    }
+    return e, true
+}
+
+// overlayEntry 分片数据被快照共享期间的一次修改，deleted表示key已被删除
+type overlayEntry[V any] struct {
+    e       entry[V]
+    deleted bool
+}
+
+// layer 分片数据的只读视图，overlay中的修改覆盖base中的数据，size为数据数量
+type layer[K comparable, V any] struct {
+    base    map[K]entry[V]
+    overlay map[K]overlayEntry[V]
+    size    int
+}
+
+// get 获取数据项，不判断是否过期
+func (l layer[K, V]) get(key K) (entry[V], bool) {
+    if o, ok := l.overlay[key]; ok {
+        return o.e, !o.deleted
+    }
+    e, ok := l.base[key]
+    return e, ok
+}
+
+// all 遍历所有数据项，不判断是否过期
+func (l layer[K, V]) all() iter.Seq2[K, entry[V]] {
+    return func(yield func(K, entry[V]) bool) {
+        for k, o := range l.overlay {
+            if !o.deleted && !yield(k, o.e) {
+                return
+            }
+        }
+        for k, e := range l.base {
+            if _, changed := l.overlay[k]; changed {
+                continue
+            }
+            if !yield(k, e) {
+                return
+            }
+        }
+    }
+}
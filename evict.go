@@ -0,0 +1,87 @@
+package smap
+
+// EvictReason 数据被淘汰的原因
+type EvictReason int
+
+const (
+    // EvictCapacity 超出容量被淘汰
+    EvictCapacity EvictReason = iota + 1
+    // EvictExpired 过期被清理
+    EvictExpired
+    // EvictCost 超出成本预算被淘汰
+    EvictCost
+)
+
+// String 淘汰原因的名称
+func (r EvictReason) String() string {
+    switch r {
+    case EvictCapacity:
+        return "capacity"
+    case EvictExpired:
+        return "expired"
+    case EvictCost:
+        return "cost"
+    }
+    return "unknown"
+}
+
+// eviction 待回调的淘汰记录
+type eviction[K comparable, V any] struct {
+    key    K
+    value  V
+    reason EvictReason
+}
+
+// evictLocked 淘汰数据项并记录淘汰信息，需在持有分片写锁时调用
+func (a *Map[K, V]) evictLocked(s *shard[K, V], reason EvictReason, keys ...K) {
+    for _, key := range keys {
+        e, ok := s.current().get(key)
+        if !ok {
+            continue
+        }
+        a.stats.evictions.Add(1)
+        if a.onEvict != nil {
+            s.evicted = append(s.evicted, eviction[K, V]{key: key, value: e.value, reason: reason})
+        }
+        if a.watching() {
+            a.emit(Event[K, V]{Op: OpEvict, Key: key, Old: e.value, HasOld: true, Reason: reason})
+        }
+    }
+    a.deleteLocked(s, keys...)
+}
+
+// shrinkLocked 超出分片容量或成本预算时按淘汰策略淘汰数据，需在持有分片写锁时调用
+func (a *Map[K, V]) shrinkLocked(s *shard[K, V]) {
+    now := a.now()
+    for {
+        reason, over := s.overflow()
+        if !over {
+            return
+        }
+        key, ok := s.policy.Evict()
+        if !ok {
+            return
+        }
+        if e, ok := s.current().get(key); ok && e.expired(now) {
+            reason = EvictExpired
+        }
+        a.evictLocked(s, reason, key)
+    }
+}
+
+// touchLocked 记录key被访问，需在持有分片写锁时调用
+func (a *Map[K, V]) touchLocked(s *shard[K, V], key K) {
+    if s.policy != nil {
+        s.policy.Access(key)
+    }
+}
+
+// unlock 释放分片写锁，并在锁外执行淘汰回调
+func (a *Map[K, V]) unlock(s *shard[K, V]) {
+    evicted := s.evicted
+    s.evicted = nil
+    s.unlock()
+    for _, ev := range evicted {
+        a.onEvict(ev.key, ev.value, ev.reason)
+    }
+}
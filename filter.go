@@ -0,0 +1,264 @@
+package smap
+
+import (
+    "encoding/json"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// filterExpr JSONPath过滤表达式
+type filterExpr interface {
+    // eval 以cur为@计算表达式，路径表达式返回nodeList
+    eval(root, cur queryNode) interface{}
+}
+
+// nodeList 路径表达式匹配到的节点
+type nodeList []queryNode
+
+// filterLiteral 字面量
+type filterLiteral struct {
+    value interface{}
+}
+
+// filterPath 以@或$开头的路径
+type filterPath struct {
+    relative bool
+    steps    []queryStep
+}
+
+// filterNot 逻辑非
+type filterNot struct {
+    x filterExpr
+}
+
+// filterBinary 比较或逻辑运算
+type filterBinary struct {
+    op   string
+    l, r filterExpr
+}
+
+func (e filterLiteral) eval(root, cur queryNode) interface{} {
+    return e.value
+}
+
+func (e filterPath) eval(root, cur queryNode) interface{} {
+    start := root
+    if e.relative {
+        start = cur
+    }
+    return nodeList(evalSteps(root, start, e.steps))
+}
+
+func (e filterNot) eval(root, cur queryNode) interface{} {
+    return !truthy(e.x.eval(root, cur))
+}
+
+func (e filterBinary) eval(root, cur queryNode) interface{} {
+    switch e.op {
+    case "&&":
+        return truthy(e.l.eval(root, cur)) && truthy(e.r.eval(root, cur))
+    case "||":
+        return truthy(e.l.eval(root, cur)) || truthy(e.r.eval(root, cur))
+    }
+    return compareValues(e.op, e.l.eval(root, cur), e.r.eval(root, cur))
+}
+
+// truthy 计算过滤表达式的真假，路径表达式匹配到节点即为真
+func truthy(v interface{}) bool {
+    switch b := v.(type) {
+    case nodeList:
+        return len(b) > 0
+    case bool:
+        return b
+    }
+    return false
+}
+
+// singular 获取比较运算的操作数，路径表达式只有匹配到一个节点时才有值
+func singular(v interface{}) (interface{}, bool) {
+    if nodes, ok := v.(nodeList); ok {
+        if len(nodes) != 1 {
+            return nil, false
+        }
+        return nodes[0].value, true
+    }
+    return v, true
+}
+
+// number 把数值类型转换为float64
+func number(v interface{}) (float64, bool) {
+    if n, ok := v.(json.Number); ok {
+        f, err := n.Float64()
+        return f, err == nil
+    }
+    switch reflect.ValueOf(v).Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+        reflect.Float32, reflect.Float64:
+        f, err := toFloat64(v)
+        return f, err == nil
+    }
+    return 0, false
+}
+
+// compareValues 比较两个操作数，数值按大小比较，字符串按字典序比较，其他类型只支持相等比较
+// 路径没有匹配到唯一节点时视为空值，两个空值相等，空值与任何值都不相等
+func compareValues(op string, l, r interface{}) bool {
+    lv, lok := singular(l)
+    rv, rok := singular(r)
+    if !lok || !rok {
+        return orderedResult(op, boolCompare(lok == rok), false)
+    }
+    if lf, ok := number(lv); ok {
+        if rf, ok := number(rv); ok {
+            return orderedResult(op, compareOrdered(lf, rf), true)
+        }
+    }
+    if ls, ok := lv.(string); ok {
+        if rs, ok := rv.(string); ok {
+            return orderedResult(op, strings.Compare(ls, rs), true)
+        }
+    }
+    return orderedResult(op, boolCompare(reflect.DeepEqual(lv, rv)), false)
+}
+
+// boolCompare 把相等结果转换为比较结果，不相等时返回1
+func boolCompare(equal bool) int {
+    if equal {
+        return 0
+    }
+    return 1
+}
+
+// orderedResult 根据比较结果计算运算符的结果，ordered为false时<和>总是为false
+func orderedResult(op string, cmp int, ordered bool) bool {
+    switch op {
+    case "==":
+        return cmp == 0
+    case "!=":
+        return cmp != 0
+    case "<":
+        return ordered && cmp < 0
+    case "<=":
+        return cmp == 0 || (ordered && cmp < 0)
+    case ">":
+        return ordered && cmp > 0
+    case ">=":
+        return cmp == 0 || (ordered && cmp > 0)
+    }
+    return false
+}
+
+// compareOrdered 比较两个数值
+func compareOrdered(a, b float64) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
+
+// parseOr 解析||表达式
+func (p *pathParser) parseOr() (filterExpr, error) {
+    l, err := p.parseAnd()
+    for err == nil && p.consume("||") {
+        var r filterExpr
+        if r, err = p.parseAnd(); err == nil {
+            l = filterBinary{op: "||", l: l, r: r}
+        }
+    }
+    return l, err
+}
+
+// parseAnd 解析&&表达式
+func (p *pathParser) parseAnd() (filterExpr, error) {
+    l, err := p.parseUnary()
+    for err == nil && p.consume("&&") {
+        var r filterExpr
+        if r, err = p.parseUnary(); err == nil {
+            l = filterBinary{op: "&&", l: l, r: r}
+        }
+    }
+    return l, err
+}
+
+// parseUnary 解析!表达式
+func (p *pathParser) parseUnary() (filterExpr, error) {
+    p.skipSpace()
+    if p.peek() == '!' && !strings.HasPrefix(p.expr[p.pos:], "!=") {
+        p.pos++
+        x, err := p.parseUnary()
+        return filterNot{x: x}, err
+    }
+    return p.parseComparison()
+}
+
+// parseComparison 解析比较表达式
+func (p *pathParser) parseComparison() (filterExpr, error) {
+    l, err := p.parsePrimary()
+    if err != nil {
+        return nil, err
+    }
+    for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+        if p.consume(op) {
+            r, err := p.parsePrimary()
+            return filterBinary{op: op, l: l, r: r}, err
+        }
+    }
+    return l, nil
+}
+
+// parsePrimary 解析括号、路径或字面量
+func (p *pathParser) parsePrimary() (filterExpr, error) {
+    p.skipSpace()
+    switch c := p.peek(); {
+    case c == '(':
+        p.pos++
+        x, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        if !p.consume(")") {
+            return nil, p.errorf("expected ')'")
+        }
+        return x, nil
+    case c == '@' || c == '$':
+        p.pos++
+        steps, err := p.parseSteps()
+        return filterPath{relative: c == '@', steps: steps}, err
+    case c == '\'' || c == '"':
+        s, err := p.parseString()
+        return filterLiteral{value: s}, err
+    case c == '-' || ('0' <= c && c <= '9'):
+        start := p.pos
+        p.pos++
+        for p.pos < len(p.expr) && strings.IndexByte("0123456789.eE+-", p.expr[p.pos]) >= 0 {
+            p.pos++
+        }
+        f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+        if err != nil {
+            return nil, p.errorf("bad number %q", p.expr[start:p.pos])
+        }
+        return filterLiteral{value: f}, nil
+    }
+    for word, value := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+        if strings.HasPrefix(p.expr[p.pos:], word) {
+            p.pos += len(word)
+            return filterLiteral{value: value}, nil
+        }
+    }
+    return nil, p.errorf("expected expression")
+}
+
+// consume 跳过空白后如果下一个token为s则跳过并返回true
+func (p *pathParser) consume(s string) bool {
+    p.skipSpace()
+    if strings.HasPrefix(p.expr[p.pos:], s) {
+        p.pos += len(s)
+        return true
+    }
+    return false
+}
@@ -0,0 +1,110 @@
+package smap
+
+import (
+    "container/heap"
+)
+
+// gdsfItem gdsf中记录的key
+type gdsfItem[K comparable] struct {
+    key      K
+    freq     float64
+    cost     float64
+    priority float64
+    index    int
+}
+
+// gdsfHeap 按优先级排序的小顶堆
+type gdsfHeap[K comparable] []*gdsfItem[K]
+
+func (h gdsfHeap[K]) Len() int {
+    return len(h)
+}
+
+func (h gdsfHeap[K]) Less(i, j int) bool {
+    return h[i].priority < h[j].priority
+}
+
+func (h gdsfHeap[K]) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *gdsfHeap[K]) Push(x interface{}) {
+    item := x.(*gdsfItem[K])
+    item.index = len(*h)
+    *h = append(*h, item)
+}
+
+func (h *gdsfHeap[K]) Pop() interface{} {
+    old := *h
+    n := len(old)
+    item := old[n-1]
+    old[n-1] = nil
+    *h = old[:n-1]
+    return item
+}
+
+// gdsf 按成本加权的GreedyDual-Size-Frequency淘汰策略
+type gdsf[K comparable] struct {
+    heap  gdsfHeap[K]
+    items map[K]*gdsfItem[K]
+    // clock 最近一次被淘汰key的优先级，随淘汰逐步升高使长期未访问的key老化
+    clock float64
+}
+
+// NewCostPolicy 创建按成本加权的淘汰策略(GreedyDual-Size-Frequency)
+// key的优先级为 clock + 访问次数/成本，成本高且访问少的key最先被淘汰，
+// 配合WithMaxCost与SetWithCost使用，使有限的成本预算尽量容纳更多的热点数据
+func NewCostPolicy[K comparable]() EvictionPolicy[K] {
+    return &gdsf[K]{items: make(map[K]*gdsfItem[K])}
+}
+
+// Add 记录写入的key，已存在的key视为一次访问并更新成本
+func (g *gdsf[K]) Add(key K, cost int64) {
+    if cost < 1 {
+        cost = 1
+    }
+    if item, ok := g.items[key]; ok {
+        item.cost = float64(cost)
+        g.touch(item)
+        return
+    }
+    item := &gdsfItem[K]{key: key, freq: 1, cost: float64(cost)}
+    item.priority = g.clock + item.freq/item.cost
+    g.items[key] = item
+    heap.Push(&g.heap, item)
+}
+
+// Access 记录key被读取
+func (g *gdsf[K]) Access(key K) {
+    if item, ok := g.items[key]; ok {
+        g.touch(item)
+    }
+}
+
+// touch 增加访问次数并重新计算优先级
+func (g *gdsf[K]) touch(item *gdsfItem[K]) {
+    item.freq++
+    item.priority = g.clock + item.freq/item.cost
+    heap.Fix(&g.heap, item.index)
+}
+
+// Remove 移除key
+func (g *gdsf[K]) Remove(key K) {
+    if item, ok := g.items[key]; ok {
+        heap.Remove(&g.heap, item.index)
+        delete(g.items, key)
+    }
+}
+
+// Evict 淘汰优先级最低的key
+func (g *gdsf[K]) Evict() (key K, ok bool) {
+    if len(g.heap) == 0 {
+        return key, false
+    }
+    item := heap.Pop(&g.heap).(*gdsfItem[K])
+    delete(g.items, item.key)
+    g.clock = item.priority
+    return item.key, true
+}
@@ -1,3 +1,3 @@
 module github.com/sqmt/smap
 
-go 1.16
+go 1.24
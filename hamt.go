@@ -0,0 +1,298 @@
+package smap
+
+import (
+    "hash/maphash"
+    "math/bits"
+)
+
+// hamtBits 每层使用的哈希位数，每个节点最多32个子项
+const hamtBits = 5
+
+// ImmutableMap 基于哈希数组映射字典树(HAMT)的不可变map
+// Set/Remove不修改原map，而是返回与原map共享未修改部分的新map，可在协程间无锁共享
+type ImmutableMap[K comparable, V any] struct {
+    seed maphash.Seed
+    root *hamtNode[K, V]
+    size int
+}
+
+// hamtNode 字典树节点，bitmap中的第i位表示第i个子项是否存在，slots按位序紧凑存放
+type hamtNode[K comparable, V any] struct {
+    bitmap uint32
+    slots  []hamtSlot[K, V]
+}
+
+// hamtSlot 节点的子项，node和leaf只有一个不为nil
+type hamtSlot[K comparable, V any] struct {
+    node *hamtNode[K, V]
+    leaf *hamtLeaf[K, V]
+}
+
+// hamtLeaf 叶子，存放哈希值完全相同的所有k/v
+type hamtLeaf[K comparable, V any] struct {
+    hash  uint64
+    pairs []entryPair[K, V]
+}
+
+// NewImmutableMap 创建一个空的ImmutableMap
+func NewImmutableMap[K comparable, V any]() *ImmutableMap[K, V] {
+    return &ImmutableMap[K, V]{seed: maphash.MakeSeed(), root: &hamtNode[K, V]{}}
+}
+
+// Immutable 把当前数据转换为ImmutableMap
+func (a *Map[K, V]) Immutable() *ImmutableMap[K, V] {
+    im := NewImmutableMap[K, V]()
+    a.SnapshotRange(func(key K, value V) bool {
+        im.root, _ = im.root.set(0, im.hash(key), key, value)
+        im.size++
+        return true
+    })
+    return im
+}
+
+// Map 把数据复制到新建的Map中，opts为新建Map的配置项
+func (im *ImmutableMap[K, V]) Map(opts ...Option) *Map[K, V] {
+    m := NewMapWith[K, V](opts...)
+    im.Range(func(key K, value V) bool {
+        m.Set(key, value)
+        return true
+    })
+    return m
+}
+
+// hash 计算key的哈希值，对不可哈希的key会panic
+func (im *ImmutableMap[K, V]) hash(key K) uint64 {
+    return maphash.Comparable(im.seed, key)
+}
+
+// Get 获取value
+func (im *ImmutableMap[K, V]) Get(key K) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    hash := im.hash(key)
+    n := im.root
+    for shift := uint(0); ; shift += hamtBits {
+        slot, found := n.slot(hash, shift)
+        if !found {
+            return val, false, nil
+        }
+        if slot.node != nil {
+            n = slot.node
+            continue
+        }
+        if i := slot.leaf.find(hash, key); i >= 0 {
+            return slot.leaf.pairs[i].value, true, nil
+        }
+        return val, false, nil
+    }
+}
+
+// Has 检查key是否存在
+func (im *ImmutableMap[K, V]) Has(key K) bool {
+    _, ok, _ := im.Get(key)
+    return ok
+}
+
+// Set 返回设置了k/v的新map，原map不变
+func (im *ImmutableMap[K, V]) Set(key K, value V) (m *ImmutableMap[K, V], err error) {
+    defer errorRecover(&err)
+    root, added := im.root.set(0, im.hash(key), key, value)
+    m = &ImmutableMap[K, V]{seed: im.seed, root: root, size: im.size}
+    if added {
+        m.size++
+    }
+
+    return m, err
+}
+
+// Remove 返回移除了单个或多个key的新map，原map不变
+func (im *ImmutableMap[K, V]) Remove(keys ...K) (m *ImmutableMap[K, V], err error) {
+    defer errorRecover(&err)
+    m = &ImmutableMap[K, V]{seed: im.seed, root: im.root, size: im.size}
+    for _, key := range keys {
+        root, removed := m.root.remove(0, im.hash(key), key)
+        if removed {
+            m.root = root
+            m.size--
+        }
+    }
+
+    return m, err
+}
+
+// Keys 获取所有key
+func (im *ImmutableMap[K, V]) Keys() []K {
+    keys := make([]K, 0, im.size)
+    im.Range(func(key K, _ V) bool {
+        keys = append(keys, key)
+        return true
+    })
+    return keys
+}
+
+// Size 获取数据长度
+func (im *ImmutableMap[K, V]) Size() int {
+    return im.size
+}
+
+// All 获取所有数据的副本
+func (im *ImmutableMap[K, V]) All() map[K]V {
+    kvs := make(map[K]V, im.size)
+    im.Range(func(key K, value V) bool {
+        kvs[key] = value
+        return true
+    })
+    return kvs
+}
+
+// Range 遍历所有数据，fn返回false时停止遍历
+func (im *ImmutableMap[K, V]) Range(fn func(key K, value V) bool) {
+    im.root.walk(fn)
+}
+
+// index 计算hash在当前层的位及其在slots中的下标
+func (n *hamtNode[K, V]) index(hash uint64, shift uint) (bit uint32, pos int) {
+    bit = 1 << ((hash >> shift) & (1<<hamtBits - 1))
+    return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// slot 获取hash在当前层对应的子项
+func (n *hamtNode[K, V]) slot(hash uint64, shift uint) (hamtSlot[K, V], bool) {
+    bit, pos := n.index(hash, shift)
+    if n.bitmap&bit == 0 {
+        return hamtSlot[K, V]{}, false
+    }
+    return n.slots[pos], true
+}
+
+// with 返回替换、插入或删除了pos处子项的节点副本
+func (n *hamtNode[K, V]) with(bit uint32, pos int, slot *hamtSlot[K, V]) *hamtNode[K, V] {
+    c := &hamtNode[K, V]{bitmap: n.bitmap}
+    switch {
+    case slot == nil:
+        c.bitmap &^= bit
+        c.slots = append(append(make([]hamtSlot[K, V], 0, len(n.slots)-1), n.slots[:pos]...), n.slots[pos+1:]...)
+    case n.bitmap&bit == 0:
+        c.bitmap |= bit
+        c.slots = make([]hamtSlot[K, V], 0, len(n.slots)+1)
+        c.slots = append(append(append(c.slots, n.slots[:pos]...), *slot), n.slots[pos:]...)
+    default:
+        c.slots = append(make([]hamtSlot[K, V], 0, len(n.slots)), n.slots...)
+        c.slots[pos] = *slot
+    }
+    return c
+}
+
+// set 返回设置了k/v的节点副本，added表示是否新增了key
+func (n *hamtNode[K, V]) set(shift uint, hash uint64, key K, value V) (*hamtNode[K, V], bool) {
+    bit, pos := n.index(hash, shift)
+    if n.bitmap&bit == 0 {
+        leaf := &hamtLeaf[K, V]{hash: hash, pairs: []entryPair[K, V]{{key: key, value: value}}}
+        return n.with(bit, pos, &hamtSlot[K, V]{leaf: leaf}), true
+    }
+    slot := n.slots[pos]
+    if slot.node != nil {
+        child, added := slot.node.set(shift+hamtBits, hash, key, value)
+        return n.with(bit, pos, &hamtSlot[K, V]{node: child}), added
+    }
+    if slot.leaf.hash == hash {
+        leaf, added := slot.leaf.set(key, value)
+        return n.with(bit, pos, &hamtSlot[K, V]{leaf: leaf}), added
+    }
+    leaf := &hamtLeaf[K, V]{hash: hash, pairs: []entryPair[K, V]{{key: key, value: value}}}
+    child := merge(shift+hamtBits, slot.leaf, leaf)
+    return n.with(bit, pos, &hamtSlot[K, V]{node: child}), true
+}
+
+// merge 创建包含两个哈希值不同的叶子的节点
+func merge[K comparable, V any](shift uint, a, b *hamtLeaf[K, V]) *hamtNode[K, V] {
+    n := &hamtNode[K, V]{}
+    bitA, _ := n.index(a.hash, shift)
+    bitB, _ := n.index(b.hash, shift)
+    if bitA == bitB {
+        n.bitmap = bitA
+        n.slots = []hamtSlot[K, V]{{node: merge(shift+hamtBits, a, b)}}
+        return n
+    }
+    n.bitmap = bitA | bitB
+    if bitA < bitB {
+        n.slots = []hamtSlot[K, V]{{leaf: a}, {leaf: b}}
+    } else {
+        n.slots = []hamtSlot[K, V]{{leaf: b}, {leaf: a}}
+    }
+    return n
+}
+
+// remove 返回删除了key的节点副本，removed表示key是否存在
+func (n *hamtNode[K, V]) remove(shift uint, hash uint64, key K) (*hamtNode[K, V], bool) {
+    bit, pos := n.index(hash, shift)
+    if n.bitmap&bit == 0 {
+        return n, false
+    }
+    slot := n.slots[pos]
+    if slot.node != nil {
+        child, removed := slot.node.remove(shift+hamtBits, hash, key)
+        if !removed {
+            return n, false
+        }
+        switch {
+        case len(child.slots) == 0:
+            return n.with(bit, pos, nil), true
+        case len(child.slots) == 1 && child.slots[0].leaf != nil:
+            // 只剩一个叶子时上移，保持字典树紧凑
+            return n.with(bit, pos, &child.slots[0]), true
+        }
+        return n.with(bit, pos, &hamtSlot[K, V]{node: child}), true
+    }
+    i := slot.leaf.find(hash, key)
+    if i < 0 {
+        return n, false
+    }
+    if len(slot.leaf.pairs) == 1 {
+        return n.with(bit, pos, nil), true
+    }
+    leaf := &hamtLeaf[K, V]{hash: hash, pairs: make([]entryPair[K, V], 0, len(slot.leaf.pairs)-1)}
+    leaf.pairs = append(append(leaf.pairs, slot.leaf.pairs[:i]...), slot.leaf.pairs[i+1:]...)
+    return n.with(bit, pos, &hamtSlot[K, V]{leaf: leaf}), true
+}
+
+// walk 按字典树顺序遍历节点下的所有数据，fn返回false时停止并返回false
+func (n *hamtNode[K, V]) walk(fn func(key K, value V) bool) bool {
+    for _, slot := range n.slots {
+        if slot.node != nil {
+            if !slot.node.walk(fn) {
+                return false
+            }
+            continue
+        }
+        for _, p := range slot.leaf.pairs {
+            if !fn(p.key, p.value) {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// find 查找key在叶子中的下标，不存在时返回-1
+func (l *hamtLeaf[K, V]) find(hash uint64, key K) int {
+    if l.hash != hash {
+        return -1
+    }
+    for i, p := range l.pairs {
+        if p.key == key {
+            return i
+        }
+    }
+    return -1
+}
+
+// set 返回设置了k/v的叶子副本，added表示是否新增了key
+func (l *hamtLeaf[K, V]) set(key K, value V) (*hamtLeaf[K, V], bool) {
+    c := &hamtLeaf[K, V]{hash: l.hash, pairs: append(make([]entryPair[K, V], 0, len(l.pairs)+1), l.pairs...)}
+    if i := l.find(l.hash, key); i >= 0 {
+        c.pairs[i].value = value
+        return c, false
+    }
+    c.pairs = append(c.pairs, entryPair[K, V]{key: key, value: value})
+    return c, true
+}
@@ -0,0 +1,119 @@
+package smap
+
+import (
+    "math/rand"
+    "reflect"
+    "testing"
+)
+
+func TestImmutableMap(t *testing.T) {
+    tests := []struct {
+        name   string
+        set    map[string]int
+        remove []string
+        want   map[string]int
+    }{
+        {name: "empty", set: nil, want: map[string]int{}},
+        {name: "set", set: map[string]int{"a": 1, "b": 2}, want: map[string]int{"a": 1, "b": 2}},
+        {name: "remove", set: map[string]int{"a": 1, "b": 2}, remove: []string{"a", "c"}, want: map[string]int{"b": 2}},
+        {name: "remove all", set: map[string]int{"a": 1, "b": 2}, remove: []string{"a", "b"}, want: map[string]int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            empty := NewImmutableMap[string, int]()
+            m := empty
+            for k, v := range tt.set {
+                m, _ = m.Set(k, v)
+            }
+            m, _ = m.Remove(tt.remove...)
+            if got := m.All(); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("All() = %v, want %v", got, tt.want)
+            }
+            if m.Size() != len(tt.want) || len(m.Keys()) != len(tt.want) {
+                t.Errorf("Size() = %d, Keys() = %v, want %d", m.Size(), m.Keys(), len(tt.want))
+            }
+            for k, v := range tt.want {
+                if got, ok, err := m.Get(k); !ok || err != nil || got != v || !m.Has(k) {
+                    t.Errorf("Get(%q) = %v, %v, %v, want %v", k, got, ok, err, v)
+                }
+            }
+            if empty.Size() != 0 {
+                t.Errorf("Set() modified the original map")
+            }
+        })
+    }
+}
+
+func TestImmutableMap_Persistent(t *testing.T) {
+    history := []*ImmutableMap[int, int]{NewImmutableMap[int, int]()}
+    models := []map[int]int{{}}
+    r := rand.New(rand.NewSource(1))
+    for i := 0; i < 3000; i++ {
+        m, model := history[len(history)-1], make(map[int]int)
+        for k, v := range models[len(models)-1] {
+            model[k] = v
+        }
+        key := r.Intn(500)
+        if r.Intn(3) == 0 {
+            m, _ = m.Remove(key)
+            delete(model, key)
+        } else {
+            m, _ = m.Set(key, i)
+            model[key] = i
+        }
+        history = append(history, m)
+        models = append(models, model)
+    }
+    for i, m := range history {
+        if got := m.All(); !reflect.DeepEqual(got, models[i]) || m.Size() != len(models[i]) {
+            t.Fatalf("version %d: All() = %d keys, want %d", i, len(got), len(models[i]))
+        }
+    }
+}
+
+func TestImmutableMap_Collision(t *testing.T) {
+    root := &hamtNode[string, int]{}
+    root, _ = root.set(0, 42, "a", 1)
+    root, _ = root.set(0, 42, "b", 2)
+    root, _ = root.set(0, 42|1<<40, "c", 3)
+    root, added := root.set(0, 42, "a", 10)
+    im := &ImmutableMap[string, int]{root: root, size: 3}
+    want := map[string]int{"a": 10, "b": 2, "c": 3}
+    if got := im.All(); added || !reflect.DeepEqual(got, want) {
+        t.Errorf("All() = %v, added %v, want %v", got, added, want)
+    }
+    root, _ = root.remove(0, 42|1<<40, "c")
+    root, _ = root.remove(0, 42, "a")
+    if len(root.slots) != 1 || root.slots[0].leaf == nil || len(root.slots[0].leaf.pairs) != 1 {
+        t.Errorf("remove() did not collapse to a single leaf: %+v", root.slots)
+    }
+}
+
+func TestImmutableMap_Convert(t *testing.T) {
+    m := NewMapAny(true)
+    m.Set("a", 1)
+    m.Set(2, "b")
+    im := m.Immutable()
+    m.Set("a", 100)
+    if v, _, _ := im.Get("a"); v != 1 || im.Size() != 2 {
+        t.Errorf("Immutable() = %v, want copy of data", im.All())
+    }
+    im, _ = im.Set(3, "c")
+    got := im.Map(WithSafe())
+    if want := map[interface{}]interface{}{"a": 1, 2: "b", 3: "c"}; !reflect.DeepEqual(got.All(), want) {
+        t.Errorf("Map() = %v, want %v", got.All(), want)
+    }
+    if _, err := im.Set([]int{1}, 1); err == nil {
+        t.Errorf("Set() unhashable key error = nil")
+    }
+    if keys := NewMapStrAny().Immutable().Keys(); len(keys) != 0 {
+        t.Errorf("Keys() = %v, want empty", keys)
+    }
+}
+
+func BenchmarkImmutableMap_Set(b *testing.B) {
+    m := NewImmutableMap[int, int]()
+    for i := 0; i < b.N; i++ {
+        m, _ = m.Set(i&0xffff, i)
+    }
+}
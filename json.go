@@ -0,0 +1,233 @@
+package smap
+
+import (
+    "bytes"
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sort"
+    "strconv"
+)
+
+// keyEnvelope MapAny编码JSON时的数据项，Type记录key的类型以便解码时还原
+type keyEnvelope struct {
+    Type  string          `json:"type"`
+    Key   json.RawMessage `json:"key"`
+    Value json.RawMessage `json:"value"`
+}
+
+// keyTypes 支持编码为keyEnvelope的key类型
+var keyTypes = map[string]func(raw json.Number, s string) (interface{}, error){
+    "string": func(_ json.Number, s string) (interface{}, error) { return s, nil },
+    "bool":   func(_ json.Number, s string) (interface{}, error) { return strconv.ParseBool(s) },
+    "int":    parseKeyInt[int],
+    "int8":   parseKeyInt[int8],
+    "int16":  parseKeyInt[int16],
+    "int32":  parseKeyInt[int32],
+    "int64":  parseKeyInt[int64],
+    "uint":   parseKeyUint[uint],
+    "uint8":  parseKeyUint[uint8],
+    "uint16": parseKeyUint[uint16],
+    "uint32": parseKeyUint[uint32],
+    "uint64": parseKeyUint[uint64],
+    "float32": func(n json.Number, _ string) (interface{}, error) {
+        f, err := strconv.ParseFloat(n.String(), 32)
+        return float32(f), err
+    },
+    "float64": func(n json.Number, _ string) (interface{}, error) { return n.Float64() },
+}
+
+// parseKeyInt 解析有符号整数key
+func parseKeyInt[T int | int8 | int16 | int32 | int64](n json.Number, _ string) (interface{}, error) {
+    i, err := strconv.ParseInt(n.String(), 10, int(reflect.TypeFor[T]().Size())*8)
+    return T(i), err
+}
+
+// parseKeyUint 解析无符号整数key
+func parseKeyUint[T uint | uint8 | uint16 | uint32 | uint64](n json.Number, _ string) (interface{}, error) {
+    i, err := strconv.ParseUint(n.String(), 10, int(reflect.TypeFor[T]().Size())*8)
+    return T(i), err
+}
+
+// interfaceKey 检查key类型是否为接口，接口类型的key使用keyEnvelope编码
+func interfaceKey[K comparable]() bool {
+    return reflect.TypeFor[K]().Kind() == reflect.Interface
+}
+
+// MarshalJSON 实现json.Marshaler，编码期间持有所有分片的读锁
+// key为string、整数或实现了encoding.TextMarshaler时编码为JSON对象
+// key为interface{}时（如MapAny）编码为[{"type":"int","key":1,"value":...}]形式的数组，解码时按type还原key
+func (a *Map[K, V]) MarshalJSON() (data []byte, err error) {
+    defer errorRecover(&err)
+    if a == nil {
+        // 零值的MapStrAny内嵌的Map为nil，与解码一致地视为空map
+        if interfaceKey[K]() {
+            return []byte("[]"), nil
+        }
+        return []byte("{}"), nil
+    }
+    a.rlockAll()
+    defer a.runlockAll()
+    now := a.now()
+    pairs := make([]entryPair[K, V], 0)
+    for _, s := range a.shards {
+        for k, e := range s.view().all() {
+            if !e.expired(now) {
+                pairs = append(pairs, entryPair[K, V]{key: k, value: e.value})
+            }
+        }
+    }
+    if interfaceKey[K]() {
+        return a.marshalEnvelopes(pairs)
+    }
+    return a.marshalObject(pairs)
+}
+
+// marshalObject 把数据编码为JSON对象
+func (a *Map[K, V]) marshalObject(pairs []entryPair[K, V]) ([]byte, error) {
+    keys := make([]string, len(pairs))
+    for i, p := range pairs {
+        key, err := keyString(p.key)
+        if err != nil {
+            return nil, err
+        }
+        keys[i] = key
+    }
+    order := make([]int, len(pairs))
+    for i := range order {
+        order[i] = i
+    }
+    if a.sortedJSON {
+        sort.Slice(order, func(i, j int) bool {
+            return keys[order[i]] < keys[order[j]]
+        })
+    }
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    for n, i := range order {
+        if n > 0 {
+            buf.WriteByte(',')
+        }
+        key, _ := json.Marshal(keys[i])
+        buf.Write(key)
+        buf.WriteByte(':')
+        value, err := json.Marshal(pairs[i].value)
+        if err != nil {
+            return nil, err
+        }
+        buf.Write(value)
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
+}
+
+// keyString 把key转换为JSON对象的key
+func keyString(key interface{}) (string, error) {
+    if tm, ok := key.(encoding.TextMarshaler); ok {
+        text, err := tm.MarshalText()
+        return string(text), err
+    }
+    rv := reflect.ValueOf(key)
+    switch rv.Kind() {
+    case reflect.String:
+        return rv.String(), nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return strconv.FormatInt(rv.Int(), 10), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return strconv.FormatUint(rv.Uint(), 10), nil
+    }
+    return "", fmt.Errorf("smap: unsupported json key type %T", key)
+}
+
+// marshalEnvelopes 把数据编码为keyEnvelope数组
+func (a *Map[K, V]) marshalEnvelopes(pairs []entryPair[K, V]) ([]byte, error) {
+    envelopes := make([]keyEnvelope, len(pairs))
+    for i, p := range pairs {
+        typ := reflect.TypeOf(p.key)
+        if typ == nil || keyTypes[typ.String()] == nil {
+            return nil, fmt.Errorf("smap: unsupported json key type %T", p.key)
+        }
+        key, err := json.Marshal(p.key)
+        if err != nil {
+            return nil, err
+        }
+        value, err := json.Marshal(p.value)
+        if err != nil {
+            return nil, err
+        }
+        envelopes[i] = keyEnvelope{Type: typ.String(), Key: key, Value: value}
+    }
+    if a.sortedJSON {
+        sort.Slice(envelopes, func(i, j int) bool {
+            if envelopes[i].Type != envelopes[j].Type {
+                return envelopes[i].Type < envelopes[j].Type
+            }
+            return bytes.Compare(envelopes[i].Key, envelopes[j].Key) < 0
+        })
+    }
+    return json.Marshal(envelopes)
+}
+
+// UnmarshalJSON 实现json.Unmarshaler，解码后的数据在一个事务中写入，已有的其他key保留
+func (a *Map[K, V]) UnmarshalJSON(data []byte) (err error) {
+    a.lazyInit()
+    kvs := make(map[K]V)
+    if interfaceKey[K]() {
+        if kvs, err = unmarshalEnvelopes[K, V](data); err != nil {
+            return err
+        }
+    } else if err = json.Unmarshal(data, &kvs); err != nil {
+        return err
+    }
+    return a.Txn(func(tx *Tx[K, V]) error {
+        for k, v := range kvs {
+            tx.Set(k, v)
+        }
+        return nil
+    })
+}
+
+// unmarshalEnvelopes 解码keyEnvelope数组并按type还原key
+func unmarshalEnvelopes[K comparable, V any](data []byte) (map[K]V, error) {
+    var envelopes []keyEnvelope
+    if err := json.Unmarshal(data, &envelopes); err != nil {
+        return nil, err
+    }
+    kvs := make(map[K]V, len(envelopes))
+    for _, env := range envelopes {
+        parse := keyTypes[env.Type]
+        if parse == nil {
+            return nil, fmt.Errorf("smap: unsupported json key type %q", env.Type)
+        }
+        var raw interface{}
+        dec := json.NewDecoder(bytes.NewReader(env.Key))
+        dec.UseNumber()
+        if err := dec.Decode(&raw); err != nil {
+            return nil, err
+        }
+        n, _ := raw.(json.Number)
+        s, _ := raw.(string)
+        if b, ok := raw.(bool); ok {
+            s = strconv.FormatBool(b)
+        }
+        key, err := parse(n, s)
+        if err != nil {
+            return nil, fmt.Errorf("smap: bad %s key %s: %w", env.Type, env.Key, err)
+        }
+        var value V
+        if err := json.Unmarshal(env.Value, &value); err != nil {
+            return nil, err
+        }
+        kvs[key.(K)] = value
+    }
+    return kvs, nil
+}
+
+// UnmarshalJSON 实现json.Unmarshaler，支持解码到零值的MapStrAny
+func (a *MapStrAny) UnmarshalJSON(data []byte) error {
+    if a.strAnyMap == nil {
+        a.strAnyMap = NewMap[string, interface{}]()
+    }
+    return a.strAnyMap.UnmarshalJSON(data)
+}
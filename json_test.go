@@ -0,0 +1,134 @@
+package smap
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestMapStrAny_MarshalJSON(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *MapStrAny
+        want string
+    }{
+        {name: "empty", a: NewMapStrAnyWith(WithSortedJSON()), want: `{}`},
+        {name: "zero", a: &MapStrAny{}, want: `{}`},
+        {name: "sorted", a: func() *MapStrAny {
+            m := NewMapStrAnyWith(WithSortedJSON(), WithShards(4))
+            for _, k := range []string{"c", "a", "b", "<"} {
+                m.Set(k, k)
+            }
+            return m
+        }(), want: `{"\u003c":"\u003c","a":"a","b":"b","c":"c"}`},
+        {name: "nested", a: func() *MapStrAny {
+            m := NewMapStrAnyWith(WithSortedJSON())
+            inner := NewMapStrAny()
+            inner.Set("x", 1)
+            m.Set("inner", inner)
+            m.Set("list", []interface{}{1, "a"})
+            return m
+        }(), want: `{"inner":{"x":1},"list":[1,"a"]}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := json.Marshal(tt.a)
+            if err != nil || string(got) != tt.want {
+                t.Errorf("Marshal() = %s, %v, want %s", got, err, tt.want)
+            }
+        })
+    }
+}
+
+func TestMap_MarshalJSON_zero(t *testing.T) {
+    tests := []struct {
+        name string
+        v    interface{}
+        want string
+    }{
+        {name: "MapStrAny value", v: MapStrAny{}, want: `{}`},
+        {name: "Map", v: &Map[string, int]{}, want: `{}`},
+        {name: "MapAny", v: &MapAny{}, want: `[]`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := json.Marshal(tt.v)
+            if err != nil || string(got) != tt.want {
+                t.Errorf("Marshal() = %s, %v, want %s", got, err, tt.want)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_UnmarshalJSON(t *testing.T) {
+    var m MapStrAny
+    if err := json.Unmarshal([]byte(`{"a":1,"b":{"c":[true]}}`), &m); err != nil {
+        t.Fatalf("Unmarshal() error = %v", err)
+    }
+    want := map[string]interface{}{"a": float64(1), "b": map[string]interface{}{"c": []interface{}{true}}}
+    if got := m.All(); !reflect.DeepEqual(got, want) {
+        t.Errorf("Unmarshal() = %v, want %v", got, want)
+    }
+    existing := NewMapStrAny(true)
+    existing.Set("keep", 1)
+    if err := json.Unmarshal([]byte(`{"a":2}`), existing); err != nil || existing.Size() != 2 {
+        t.Errorf("Unmarshal() into existing = %v, %v", existing.All(), err)
+    }
+    if err := json.Unmarshal([]byte(`[1]`), existing); err == nil {
+        t.Errorf("Unmarshal() array error = nil")
+    }
+}
+
+func TestMapAny_JSON(t *testing.T) {
+    m := NewMapAnyWith(WithSortedJSON())
+    m.Set("a", 1)
+    m.Set(2, "int")
+    m.Set(int64(2), "int64")
+    m.Set(uint8(3), []interface{}{"x"})
+    m.Set(1.5, nil)
+    m.Set(true, "bool")
+    data, err := json.Marshal(m)
+    if err != nil {
+        t.Fatalf("Marshal() error = %v", err)
+    }
+    want := `[{"type":"bool","key":true,"value":"bool"},{"type":"float64","key":1.5,"value":null},` +
+        `{"type":"int","key":2,"value":"int"},{"type":"int64","key":2,"value":"int64"},` +
+        `{"type":"string","key":"a","value":1},{"type":"uint8","key":3,"value":["x"]}]`
+    if string(data) != want {
+        t.Errorf("Marshal() = %s, want %s", data, want)
+    }
+    got := NewMapAny(true)
+    if err := json.Unmarshal(data, got); err != nil {
+        t.Fatalf("Unmarshal() error = %v", err)
+    }
+    wantAll := map[interface{}]interface{}{"a": float64(1), 2: "int", int64(2): "int64", uint8(3): []interface{}{"x"}, 1.5: nil, true: "bool"}
+    if !reflect.DeepEqual(got.All(), wantAll) {
+        t.Errorf("Unmarshal() = %v, want %v", got.All(), wantAll)
+    }
+    if err := json.Unmarshal([]byte(`[{"type":"complex","key":1,"value":1}]`), got); err == nil {
+        t.Errorf("Unmarshal() unknown key type error = nil")
+    }
+    if err := json.Unmarshal([]byte(`[{"type":"uint8","key":300,"value":1}]`), got); err == nil {
+        t.Errorf("Unmarshal() overflowing key error = nil")
+    }
+    bad := NewMapAny()
+    bad.Set(struct{}{}, 1)
+    if _, err := json.Marshal(bad); err == nil {
+        t.Errorf("Marshal() struct key error = nil")
+    }
+}
+
+func TestMap_JSON(t *testing.T) {
+    m := NewMapWith[int, time.Duration](WithSortedJSON())
+    m.Set(10, time.Second)
+    m.Set(2, time.Minute)
+    data, err := json.Marshal(m)
+    if err != nil || string(data) != `{"10":1000000000,"2":60000000000}` {
+        t.Fatalf("Marshal() = %s, %v", data, err)
+    }
+    var got Map[int, time.Duration]
+    if err := json.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got.All(), m.All()) {
+        t.Errorf("Unmarshal() = %v, %v, want %v", got.All(), err, m.All())
+    }
+}
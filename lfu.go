@@ -0,0 +1,103 @@
+package smap
+
+import (
+    "container/heap"
+)
+
+// lfuItem lfu中记录的key
+type lfuItem[K comparable] struct {
+    key   K
+    freq  uint64
+    tick  uint64
+    index int
+}
+
+// lfuHeap 按访问次数排序的小顶堆，次数相同时最久未访问的在前
+type lfuHeap[K comparable] []*lfuItem[K]
+
+func (h lfuHeap[K]) Len() int {
+    return len(h)
+}
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+    if h[i].freq != h[j].freq {
+        return h[i].freq < h[j].freq
+    }
+    return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x interface{}) {
+    item := x.(*lfuItem[K])
+    item.index = len(*h)
+    *h = append(*h, item)
+}
+
+func (h *lfuHeap[K]) Pop() interface{} {
+    old := *h
+    n := len(old)
+    item := old[n-1]
+    old[n-1] = nil
+    *h = old[:n-1]
+    return item
+}
+
+// lfu 最不经常使用淘汰策略
+type lfu[K comparable] struct {
+    heap  lfuHeap[K]
+    items map[K]*lfuItem[K]
+    tick  uint64
+}
+
+// NewLFUPolicy 创建最不经常使用(LFU)淘汰策略，访问次数最少的key最先被淘汰
+// 适合热点稳定、存在大量一次性扫描访问的场景
+func NewLFUPolicy[K comparable]() EvictionPolicy[K] {
+    return &lfu[K]{items: make(map[K]*lfuItem[K])}
+}
+
+// Add 记录写入的key，已存在的key视为一次访问
+func (l *lfu[K]) Add(key K, cost int64) {
+    if _, ok := l.items[key]; ok {
+        l.Access(key)
+        return
+    }
+    l.tick++
+    item := &lfuItem[K]{key: key, freq: 1, tick: l.tick}
+    l.items[key] = item
+    heap.Push(&l.heap, item)
+}
+
+// Access 记录key被读取
+func (l *lfu[K]) Access(key K) {
+    item, ok := l.items[key]
+    if !ok {
+        return
+    }
+    l.tick++
+    item.freq++
+    item.tick = l.tick
+    heap.Fix(&l.heap, item.index)
+}
+
+// Remove 移除key
+func (l *lfu[K]) Remove(key K) {
+    if item, ok := l.items[key]; ok {
+        heap.Remove(&l.heap, item.index)
+        delete(l.items, key)
+    }
+}
+
+// Evict 淘汰访问次数最少的key
+func (l *lfu[K]) Evict() (key K, ok bool) {
+    if len(l.heap) == 0 {
+        return key, false
+    }
+    item := heap.Pop(&l.heap).(*lfuItem[K])
+    delete(l.items, item.key)
+    return item.key, true
+}
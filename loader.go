@@ -0,0 +1,128 @@
+package smap
+
+import (
+    "errors"
+    "sync"
+    "time"
+)
+
+// loadCall 正在执行的加载调用
+type loadCall[V any] struct {
+    done chan struct{}
+    val  V
+    err  error
+}
+
+// loadFailure 缓存的加载失败结果
+type loadFailure struct {
+    err    error
+    expire time.Time
+}
+
+// loadGroup 合并同一个key的并发加载
+type loadGroup[K comparable, V any] struct {
+    mutex  sync.Mutex
+    calls  map[K]*loadCall[V]
+    failed map[K]loadFailure
+}
+
+// GetOrLoad 获取value，key不存在时调用loader加载并写入map
+// loader为nil时使用WithLoader配置的默认加载函数，两者都没有时等同于Get
+// 同一个key同一时刻只会调用一次loader，并发的调用方等待并共享同一个结果；
+// 加载期间key已被Set等写操作写入时不会覆盖，返回已写入的value；
+// loader返回的error（包括panic）通过err返回，返回ErrNotFound表示key不存在；
+// 配置了WithNegativeTTL时失败结果（包括key不存在）会缓存一段时间
+func (a *Map[K, V]) GetOrLoad(key K, loader func(key K) (V, error)) (val V, ok bool, err error) {
+    if val, ok, err = a.Get(key); ok || err != nil {
+        return val, ok, err
+    }
+    if loader == nil {
+        loader = a.loader
+    }
+    if loader == nil {
+        return val, false, nil
+    }
+    return a.load(key, loader)
+}
+
+// load 以singleflight的方式执行loader
+func (a *Map[K, V]) load(key K, loader func(key K) (V, error)) (val V, ok bool, err error) {
+    g := &a.loads
+    g.mutex.Lock()
+    if f, exists := g.failed[key]; exists {
+        if time.Now().Before(f.expire) {
+            g.mutex.Unlock()
+            return (&loadCall[V]{err: f.err}).result()
+        }
+        delete(g.failed, key)
+    }
+    if c, exists := g.calls[key]; exists {
+        g.mutex.Unlock()
+        <-c.done
+        return c.result()
+    }
+    // 等待锁期间其他调用方可能已经加载完成
+    if e, exists := a.get(a.shard(key), key, a.now()); exists {
+        g.mutex.Unlock()
+        return e.value, true, nil
+    }
+    c := &loadCall[V]{done: make(chan struct{})}
+    if g.calls == nil {
+        g.calls = make(map[K]*loadCall[V])
+    }
+    g.calls[key] = c
+    g.mutex.Unlock()
+
+    c.val, c.err = a.callLoader(key, loader)
+    if c.err == nil {
+        c.val, c.err = a.cache(key, c.val)
+    }
+
+    g.mutex.Lock()
+    delete(g.calls, key)
+    if c.err != nil && a.negativeTTL > 0 {
+        if g.failed == nil {
+            g.failed = make(map[K]loadFailure)
+        }
+        g.failed[key] = loadFailure{err: c.err, expire: time.Now().Add(a.negativeTTL)}
+    }
+    g.mutex.Unlock()
+    close(c.done)
+
+    return c.result()
+}
+
+// result 加载结果，ErrNotFound视为key不存在而不是错误
+func (c *loadCall[V]) result() (val V, ok bool, err error) {
+    if errors.Is(c.err, ErrNotFound) {
+        return val, false, nil
+    }
+    return c.val, c.err == nil, c.err
+}
+
+// cache key仍不存在时写入加载得到的value，不写入持久化存储
+// 加载期间key已被写入时保留已有的value并返回，避免用加载到的旧数据覆盖新的修改
+func (a *Map[K, V]) cache(key K, value V) (val V, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if e, ok := lookup(s.current(), key, a.now()); ok {
+        return e.value, nil
+    }
+
+    return value, a.cacheLocked(s, key, a.newEntry(value))
+}
+
+// callLoader 调用loader，loader内的panic以error形式返回
+func (a *Map[K, V]) callLoader(key K, loader func(key K) (V, error)) (val V, err error) {
+    defer errorRecover(&err)
+    return loader(key)
+}
+
+// Forget 清除key缓存的加载失败结果，下一次GetOrLoad会重新调用loader
+func (a *Map[K, V]) Forget(key K) {
+    a.loads.mutex.Lock()
+    defer a.loads.mutex.Unlock()
+    delete(a.loads.failed, key)
+}
@@ -0,0 +1,143 @@
+package smap
+
+import (
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestMap_GetOrLoad(t *testing.T) {
+    errLoad := errors.New("load failed")
+    tests := []struct {
+        name    string
+        a       *MapStrAny
+        loader  func(key string) (interface{}, error)
+        wantVal interface{}
+        wantOk  bool
+        wantErr error
+    }{
+        {name: "unsafe hit", a: getMapStrAny(map[string]interface{}{"k": 1}), loader: func(string) (interface{}, error) {
+            return 2, nil
+        }, wantVal: 1, wantOk: true},
+        {name: "unsafe load", a: getMapStrAny(nil), loader: func(key string) (interface{}, error) {
+            return key + "!", nil
+        }, wantVal: "k!", wantOk: true},
+        {name: "unsafe error", a: getMapStrAny(nil), loader: func(string) (interface{}, error) {
+            return nil, errLoad
+        }, wantVal: nil, wantOk: false, wantErr: errLoad},
+        {name: "unsafe no loader", a: getMapStrAny(nil), loader: nil, wantVal: nil, wantOk: false},
+        {name: "safe load", a: getMapStrAny(nil, true), loader: func(key string) (interface{}, error) {
+            return 3, nil
+        }, wantVal: 3, wantOk: true},
+        {name: "default loader", a: NewMapStrAnyWith(WithLoader(func(key string) (interface{}, error) {
+            return "default", nil
+        })), loader: nil, wantVal: "default", wantOk: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            val, ok, err := tt.a.GetOrLoad("k", tt.loader)
+            if !errors.Is(err, tt.wantErr) || val != tt.wantVal || ok != tt.wantOk {
+                t.Errorf("GetOrLoad() = %v, %v, %v, want %v, %v, %v", val, ok, err, tt.wantVal, tt.wantOk, tt.wantErr)
+            }
+            if got := tt.a.Has("k"); got != tt.wantOk {
+                t.Errorf("Has() = %v, want %v", got, tt.wantOk)
+            }
+        })
+    }
+}
+
+func TestMap_GetOrLoad_panic(t *testing.T) {
+    m := NewMap[string, int](true)
+    _, ok, err := m.GetOrLoad("k", func(string) (int, error) {
+        panic("boom")
+    })
+    if ok || err == nil {
+        t.Errorf("GetOrLoad() = %v, %v, want error", ok, err)
+    }
+}
+
+func TestMap_GetOrLoad_singleflight(t *testing.T) {
+    m := NewMapWith[string, int](WithShards(4))
+    var calls int32
+    release := make(chan struct{})
+    loader := func(key string) (int, error) {
+        atomic.AddInt32(&calls, 1)
+        <-release
+        return 42, nil
+    }
+    var wg sync.WaitGroup
+    results := make([]int, 16)
+    for i := range results {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            results[i], _, _ = m.GetOrLoad("k", loader)
+        }(i)
+    }
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+    if calls != 1 {
+        t.Errorf("loader called %v times, want 1", calls)
+    }
+    for _, v := range results {
+        if v != 42 {
+            t.Errorf("GetOrLoad() = %v, want 42", v)
+        }
+    }
+}
+
+func TestMap_GetOrLoad_setDuringLoad(t *testing.T) {
+    m := NewMapWith[string, int](WithSafe())
+    started := make(chan struct{})
+    release := make(chan struct{})
+    loader := func(key string) (int, error) {
+        close(started)
+        <-release
+        return 1, nil
+    }
+    done := make(chan int)
+    go func() {
+        v, _, _ := m.GetOrLoad("k", loader)
+        done <- v
+    }()
+    <-started
+    m.Set("k", 2)
+    close(release)
+    if v := <-done; v != 2 {
+        t.Errorf("GetOrLoad() = %v, want value set during load", v)
+    }
+    if v, _, _ := m.Get("k"); v != 2 {
+        t.Errorf("Get() = %v, want 2", v)
+    }
+}
+
+func TestMap_GetOrLoad_negative(t *testing.T) {
+    errLoad := errors.New("not found")
+    var calls int32
+    loader := func(key string) (int, error) {
+        atomic.AddInt32(&calls, 1)
+        return 0, errLoad
+    }
+    m := NewMapWith[string, int](WithSafe(), WithNegativeTTL(20*time.Millisecond))
+    for i := 0; i < 3; i++ {
+        if _, _, err := m.GetOrLoad("k", loader); !errors.Is(err, errLoad) {
+            t.Fatalf("GetOrLoad() error = %v, want %v", err, errLoad)
+        }
+    }
+    if calls != 1 {
+        t.Errorf("loader called %v times, want 1", calls)
+    }
+    m.Forget("k")
+    m.GetOrLoad("k", loader)
+    if calls != 2 {
+        t.Errorf("loader called %v times after Forget, want 2", calls)
+    }
+    time.Sleep(30 * time.Millisecond)
+    m.GetOrLoad("k", loader)
+    if calls != 3 {
+        t.Errorf("loader called %v times after negative ttl, want 3", calls)
+    }
+}
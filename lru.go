@@ -0,0 +1,52 @@
+package smap
+
+import (
+    "container/list"
+)
+
+// lru 最近最少使用淘汰策略
+type lru[K comparable] struct {
+    list  *list.List
+    items map[K]*list.Element
+}
+
+// NewLRUPolicy 创建最近最少使用(LRU)淘汰策略，未指定淘汰策略时默认使用
+func NewLRUPolicy[K comparable]() EvictionPolicy[K] {
+    return &lru[K]{list: list.New(), items: make(map[K]*list.Element)}
+}
+
+// Add 记录写入的key
+func (l *lru[K]) Add(key K, cost int64) {
+    if el, ok := l.items[key]; ok {
+        l.list.MoveToFront(el)
+        return
+    }
+    l.items[key] = l.list.PushFront(key)
+}
+
+// Access 记录key被读取
+func (l *lru[K]) Access(key K) {
+    if el, ok := l.items[key]; ok {
+        l.list.MoveToFront(el)
+    }
+}
+
+// Remove 移除key
+func (l *lru[K]) Remove(key K) {
+    if el, ok := l.items[key]; ok {
+        l.list.Remove(el)
+        delete(l.items, key)
+    }
+}
+
+// Evict 淘汰最久未被访问的key
+func (l *lru[K]) Evict() (key K, ok bool) {
+    el := l.list.Back()
+    if el == nil {
+        return key, false
+    }
+    l.list.Remove(el)
+    key = el.Value.(K)
+    delete(l.items, key)
+    return key, true
+}
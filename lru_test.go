@@ -0,0 +1,122 @@
+package smap
+
+import (
+    "reflect"
+    "sort"
+    "testing"
+    "time"
+)
+
+func TestMap_capacity(t *testing.T) {
+    tests := []struct {
+        name     string
+        opts     []Option
+        touch    bool
+        wantKeys []string
+    }{
+        {name: "unsafe", opts: []Option{WithCapacity(2)}, wantKeys: []string{"b", "c"}},
+        {name: "unsafe touched", opts: []Option{WithCapacity(2)}, touch: true, wantKeys: []string{"a", "c"}},
+        {name: "safe", opts: []Option{WithSafe(), WithCapacity(2)}, wantKeys: []string{"b", "c"}},
+        {name: "safe touched", opts: []Option{WithSafe(), WithCapacity(2)}, touch: true, wantKeys: []string{"a", "c"}},
+        {name: "cow", opts: []Option{WithCopyOnWrite(), WithCapacity(2)}, wantKeys: []string{"b", "c"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            evicted := make([]string, 0)
+            opts := append(tt.opts, WithEvictCallback(func(key string, value int, reason EvictReason) {
+                if reason != EvictCapacity {
+                    t.Errorf("evict reason = %v, want %v", reason, EvictCapacity)
+                }
+                evicted = append(evicted, key)
+            }))
+            m := NewMapWith[string, int](opts...)
+            m.Set("a", 1)
+            m.Set("b", 2)
+            if tt.touch {
+                m.Get("a")
+            }
+            m.Set("c", 3)
+            keys := m.Keys()
+            sort.Strings(keys)
+            if !reflect.DeepEqual(keys, tt.wantKeys) {
+                t.Errorf("Keys() = %v, want %v", keys, tt.wantKeys)
+            }
+            if m.Size() != 2 {
+                t.Errorf("Size() = %v, want %v", m.Size(), 2)
+            }
+            if len(evicted) != 1 {
+                t.Errorf("evicted = %v, want one key", evicted)
+            }
+        })
+    }
+}
+
+func TestMap_capacity_update(t *testing.T) {
+    m := NewMapWith[string, int](WithCapacity(2))
+    m.Set("a", 1)
+    m.Set("b", 2)
+    m.Set("a", 10)
+    m.Set("c", 3)
+    if m.Has("b") || !m.Has("a") || !m.Has("c") {
+        t.Errorf("Keys() = %v, want [a c]", m.Keys())
+    }
+    m.Remove("a")
+    m.Set("d", 4)
+    if m.Size() != 2 || !m.Has("c") || !m.Has("d") {
+        t.Errorf("Keys() = %v, want [c d]", m.Keys())
+    }
+}
+
+func TestMap_capacity_sharded(t *testing.T) {
+    m := NewMapWith[int, int](WithShards(4), WithCapacity(100))
+    for i := 0; i < 1000; i++ {
+        m.Set(i, i)
+    }
+    if got := m.Size(); got > 100 {
+        t.Errorf("Size() = %v, want <= %v", got, 100)
+    }
+    if !m.Has(999) {
+        t.Errorf("Has(999) = false, want latest key kept")
+    }
+}
+
+func TestMap_evictExpired(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    m := NewMapWith[string, int](WithSafe(), WithEvictCallback(func(key string, value int, reason EvictReason) {
+        reasons[key] = reason
+    }))
+    m.SetWithTTL("a", 1, time.Millisecond)
+    m.SetWithTTL("b", 2, time.Millisecond)
+    time.Sleep(5 * time.Millisecond)
+    m.Get("a")
+    m.DeleteExpired()
+    want := map[string]EvictReason{"a": EvictExpired, "b": EvictExpired}
+    if !reflect.DeepEqual(reasons, want) {
+        t.Errorf("evicted = %v, want %v", reasons, want)
+    }
+}
+
+func TestWithEvictCallback_mismatch(t *testing.T) {
+    defer func() {
+        if r := recover(); r == nil {
+            t.Errorf("NewMapWith() did not panic on mismatched callback")
+        }
+    }()
+    NewMapWith[string, int](WithEvictCallback(func(key int, value int, reason EvictReason) {}))
+}
+
+func TestEvictReason_String(t *testing.T) {
+    tests := []struct {
+        r    EvictReason
+        want string
+    }{
+        {r: EvictCapacity, want: "capacity"},
+        {r: EvictExpired, want: "expired"},
+        {r: 0, want: "unknown"},
+    }
+    for _, tt := range tests {
+        if got := tt.r.String(); got != tt.want {
+            t.Errorf("String() = %v, want %v", got, tt.want)
+        }
+    }
+}
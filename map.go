@@ -0,0 +1,404 @@
+package smap
+
+import (
+    "errors"
+    "fmt"
+    "hash/maphash"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Map 泛型map结构，K为键类型，V为值类型
+// 数据存放在一个或多个分片中，每个分片独立加锁
+type Map[K comparable, V any] struct {
+    safe   bool
+    seed   maphash.Seed
+    shards []*shard[K, V]
+    // ttl 默认过期时间，0表示永不过期
+    ttl time.Duration
+    // expiring 是否存在设置了过期时间的数据
+    expiring atomic.Bool
+    closing  chan struct{}
+    closed   sync.Once
+    onEvict  func(key K, value V, reason EvictReason)
+    stats    counters
+    // loader 默认加载函数，negativeTTL 加载失败结果的缓存时间
+    loader      func(key K) (V, error)
+    negativeTTL time.Duration
+    loads       loadGroup[K, V]
+    // store 持久化存储，wg 等待后台协程退出
+    store *storeSync[K, V]
+    wg    sync.WaitGroup
+    // watchers 数据变更的订阅者
+    watchers watchers[K, V]
+    // sortedJSON 编码JSON时按key排序
+    sortedJSON bool
+    // version 最近一次分配的版本号，所有key共用以保证删除后重建的key版本号仍然递增
+    version atomic.Uint64
+    // wal 预写日志，只在通过OpenMap打开时存在
+    wal *walLog[K, V]
+}
+
+// NewMap 创建一个Map对象
+// 默认是非并发安全的，如果传入safe参数为true则开启并发安全锁
+func NewMap[K comparable, V any](safe ...bool) *Map[K, V] {
+    if len(safe) > 0 && safe[0] {
+        return NewMapWith[K, V](WithSafe())
+    }
+    return NewMapWith[K, V]()
+}
+
+// NewMapWith 使用配置项创建一个Map对象
+// 配置了清理协程或延迟写入时，不再使用后需调用Close停止
+func NewMapWith[K comparable, V any](opts ...Option) *Map[K, V] {
+    return newMapWith[K, V](newOptions(opts...))
+}
+
+// newMapWith 使用已应用的配置创建一个Map对象
+func newMapWith[K comparable, V any](o *options) *Map[K, V] {
+    m := &Map[K, V]{safe: o.safe, ttl: o.ttl, negativeTTL: o.negativeTTL, sortedJSON: o.sortedJSON}
+    if o.shards > 1 {
+        m.seed = maphash.MakeSeed()
+    }
+    m.shards = make([]*shard[K, V], o.shards)
+    for i := range m.shards {
+        m.shards[i] = newShard[K, V](o.safe, o.cow)
+    }
+    m.initPolicy(o)
+    if o.onEvict != nil {
+        m.onEvict = assertOption[func(K, V, EvictReason)]("evict callback", o.onEvict)
+    }
+    if o.loader != nil {
+        m.loader = assertOption[func(K) (V, error)]("loader", o.loader)
+    }
+    if o.ttl > 0 {
+        m.expiring.Store(true)
+    }
+    if o.janitor > 0 || o.writeMode == WriteBehind {
+        m.closing = make(chan struct{})
+    }
+    if o.store != nil {
+        m.initStore(o)
+    }
+    if o.janitor > 0 {
+        m.wg.Add(1)
+        go m.janitor(o.janitor)
+    }
+    return m
+}
+
+// lazyInit 初始化零值的Map，用于解码到未通过构造函数创建的Map
+func (a *Map[K, V]) lazyInit() {
+    if a.shards == nil {
+        a.shards = []*shard[K, V]{newShard[K, V](false, false)}
+    }
+}
+
+// errorRecover 捕获panic异常信息
+func errorRecover(err *error) {
+    if r := recover(); r != nil {
+        *err = fmt.Errorf("%v", r)
+    }
+}
+
+// shard 获取key所在的分片
+// 对不可哈希的key会panic，调用方需自行recover
+func (a *Map[K, V]) shard(key K) *shard[K, V] {
+    if len(a.shards) == 1 {
+        return a.shards[0]
+    }
+    return a.shards[a.index(key)]
+}
+
+// index 计算key所在分片的下标
+func (a *Map[K, V]) index(key K) uint64 {
+    return maphash.Comparable(a.seed, key) & uint64(len(a.shards)-1)
+}
+
+// now 获取用于判断过期的当前时间，不存在过期数据时返回0
+func (a *Map[K, V]) now() int64 {
+    if !a.expiring.Load() {
+        return 0
+    }
+    return time.Now().UnixNano()
+}
+
+// newEntry 使用默认过期时间创建数据项
+func (a *Map[K, V]) newEntry(value V) entry[V] {
+    return a.newEntryTTL(value, a.ttl)
+}
+
+// newEntryTTL 使用指定过期时间创建数据项，ttl<=0表示永不过期
+func (a *Map[K, V]) newEntryTTL(value V, ttl time.Duration) entry[V] {
+    e := entry[V]{value: value, cost: 1}
+    if ttl > 0 {
+        e.expire = time.Now().Add(ttl).UnixNano()
+    }
+    return e
+}
+
+// storeLocked 写入数据项并同步到持久化存储，需在持有分片写锁时调用
+func (a *Map[K, V]) storeLocked(s *shard[K, V], key K, e entry[V]) error {
+    return a.writeLocked(s, key, e, true)
+}
+
+// cacheLocked 只在内存中写入数据项，用于写入从外部加载的数据，需在持有分片写锁时调用
+func (a *Map[K, V]) cacheLocked(s *shard[K, V], key K, e entry[V]) error {
+    return a.writeLocked(s, key, e, false)
+}
+
+// writeLocked 写入数据项，需在持有分片写锁时调用
+// 配置了淘汰策略时先判断是否允许写入，写入后更新淘汰策略并淘汰超出容量或预算的数据
+func (a *Map[K, V]) writeLocked(s *shard[K, V], key K, e entry[V], persist bool) error {
+    old, exists := s.current().get(key)
+    if s.policy != nil && !s.admit(key, e.cost, exists) {
+        return ErrRejected
+    }
+    if persist && a.store != nil {
+        if err := a.store.write(key, e.value); err != nil {
+            return err
+        }
+    }
+    if e.version == 0 {
+        e.version = a.version.Add(1)
+    }
+    if persist && a.wal != nil {
+        if err := a.wal.append(walRecord[K, V]{Op: walSet, Key: key, Value: e.value, Expire: e.expire, Version: e.version}); err != nil {
+            return err
+        }
+    }
+    s.put(key, e)
+    s.cost += e.cost - old.cost
+    if a.watching() {
+        a.emit(Event[K, V]{Op: OpSet, Key: key, Old: old.value, HasOld: exists && !old.expired(a.now()), New: e.value})
+    }
+    if s.policy == nil {
+        return nil
+    }
+    s.policy.Add(key, e.cost)
+    a.shrinkLocked(s)
+    return nil
+}
+
+// removeLocked 删除数据项并同步到持久化存储，需在持有分片写锁时调用
+func (a *Map[K, V]) removeLocked(s *shard[K, V], keys ...K) (err error) {
+    n := len(keys)
+    if a.store != nil {
+        for i, key := range keys {
+            if err = a.store.remove(key); err != nil {
+                n = i
+                break
+            }
+        }
+    }
+    if a.wal != nil {
+        if werr := a.wal.remove(s.current(), keys[:n]...); werr != nil {
+            return errors.Join(err, werr)
+        }
+    }
+    a.dropLocked(s, keys[:n]...)
+    return err
+}
+
+// dropLocked 只在内存中删除数据项并通知订阅者，需在持有分片写锁时调用
+func (a *Map[K, V]) dropLocked(s *shard[K, V], keys ...K) {
+    if a.watching() {
+        now := a.now()
+        for _, key := range keys {
+            if e, ok := s.current().get(key); ok && !e.expired(now) {
+                a.emit(Event[K, V]{Op: OpRemove, Key: key, Old: e.value, HasOld: true})
+            }
+        }
+    }
+    a.deleteLocked(s, keys...)
+}
+
+// deleteLocked 只在内存中删除数据项，需在持有分片写锁时调用
+func (a *Map[K, V]) deleteLocked(s *shard[K, V], keys ...K) {
+    s.cost -= s.delete(keys...)
+    if s.policy != nil {
+        for _, key := range keys {
+            s.policy.Remove(key)
+        }
+    }
+}
+
+// Get 获取value，限制了容量时会更新key的访问顺序
+func (a *Map[K, V]) Get(key K) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    if e, ok := a.fetch(key); ok {
+        return e.value, ok, nil
+    }
+
+    return val, false, err
+}
+
+// fetch 获取未过期的数据项并更新访问顺序和命中统计
+func (a *Map[K, V]) fetch(key K) (e entry[V], ok bool) {
+    s := a.shard(key)
+    now := a.now()
+    if s.policy != nil && !s.cow {
+        e, ok = a.getTouch(s, key, now)
+    } else {
+        e, ok = a.get(s, key, now)
+    }
+    if ok {
+        a.stats.hits.Add(1)
+    } else {
+        a.stats.misses.Add(1)
+    }
+    return e, ok
+}
+
+// getTouch 在写锁下获取未过期的数据项并更新访问顺序
+func (a *Map[K, V]) getTouch(s *shard[K, V], key K, now int64) (entry[V], bool) {
+    s.lock()
+    defer a.unlock(s)
+    e, ok := s.current().get(key)
+    if !ok {
+        return e, false
+    }
+    if e.expired(now) {
+        a.evictLocked(s, EvictExpired, key)
+        return e, false
+    }
+    a.touchLocked(s, key)
+    return e, true
+}
+
+// get 在读锁下获取未过期的数据项，发现已过期的数据项时将其删除
+func (a *Map[K, V]) get(s *shard[K, V], key K, now int64) (entry[V], bool) {
+    e, ok := a.read(s, key)
+    if ok && e.expired(now) {
+        a.expireKey(s, key, now)
+        return e, false
+    }
+    return e, ok
+}
+
+// read 在读锁下获取数据项
+func (a *Map[K, V]) read(s *shard[K, V], key K) (entry[V], bool) {
+    s.rlock()
+    defer s.runlock()
+    e, ok := s.view().get(key)
+    return e, ok
+}
+
+// expireKey 删除已过期的key
+func (a *Map[K, V]) expireKey(s *shard[K, V], key K, now int64) {
+    s.lock()
+    defer a.unlock(s)
+    if e, ok := s.current().get(key); ok && e.expired(now) {
+        a.evictLocked(s, EvictExpired, key)
+    }
+}
+
+// Set 设置k/v，配置了默认过期时间时使用默认过期时间
+func (a *Map[K, V]) Set(key K, value V) (err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+
+    return a.storeLocked(s, key, a.newEntry(value))
+}
+
+// Has 检查key是否存在
+func (a *Map[K, V]) Has(key K) (b bool) {
+    defer func(b *bool) {
+        if r := recover(); r != nil {
+            *b = false
+        }
+    }(&b)
+    s := a.shard(key)
+    if _, ok := a.get(s, key, a.now()); ok {
+        return ok
+    }
+
+    return b
+}
+
+// Remove 移除单个或多个key
+func (a *Map[K, V]) Remove(keys ...K) (err error) {
+    if len(keys) == 0 {
+        return
+    }
+    defer errorRecover(&err)
+    if len(a.shards) == 1 {
+        return a.removeFrom(a.shards[0], keys...)
+    }
+    for _, key := range keys {
+        if err := a.removeFrom(a.shard(key), key); err != nil {
+            return err
+        }
+    }
+
+    return err
+}
+
+// removeFrom 在指定分片中移除key
+func (a *Map[K, V]) removeFrom(s *shard[K, V], keys ...K) error {
+    s.lock()
+    defer a.unlock(s)
+    return a.removeLocked(s, keys...)
+}
+
+// Keys 获取所有key
+func (a *Map[K, V]) Keys() []K {
+    keys := make([]K, 0)
+    now := a.now()
+    for _, s := range a.shards {
+        s.rlock()
+        for k, e := range s.view().all() {
+            if !e.expired(now) {
+                keys = append(keys, k)
+            }
+        }
+        s.runlock()
+    }
+    return keys
+}
+
+// Size 获取数据长度，不包含已过期但尚未清理的数据
+func (a *Map[K, V]) Size() int {
+    size := 0
+    now := a.now()
+    for _, s := range a.shards {
+        s.rlock()
+        size += a.sizeOf(s.view(), now)
+        s.runlock()
+    }
+
+    return size
+}
+
+// sizeOf 统计data中未过期的数据数量
+func (a *Map[K, V]) sizeOf(data layer[K, V], now int64) int {
+    if now == 0 {
+        return data.size
+    }
+    size := 0
+    for _, e := range data.all() {
+        if !e.expired(now) {
+            size++
+        }
+    }
+    return size
+}
+
+// All 获取所有数据的副本，修改返回值不会影响map
+func (a *Map[K, V]) All() map[K]V {
+    kvs := make(map[K]V)
+    now := a.now()
+    for _, s := range a.shards {
+        s.rlock()
+        for k, e := range s.view().all() {
+            if !e.expired(now) {
+                kvs[k] = e.value
+            }
+        }
+        s.runlock()
+    }
+    return kvs
+}
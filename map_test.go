@@ -0,0 +1,268 @@
+package smap
+
+import (
+    "reflect"
+    "sort"
+    "sync"
+    "testing"
+)
+
+func getMap[K comparable, V any](data map[K]V, safe ...bool) *Map[K, V] {
+    m := NewMap[K, V](safe...)
+    for k, v := range data {
+        m.Set(k, v)
+    }
+    return m
+}
+
+func TestNewMap(t *testing.T) {
+    type args struct {
+        safe []bool
+    }
+    tests := []struct {
+        name string
+        args args
+        want *Map[string, int]
+    }{
+        {name: "unsafe", want: &Map[string, int]{safe: false, shards: []*shard[string, int]{{data: map[string]entry[int]{}}}}},
+        {name: "safe", args: args{safe: []bool{true}}, want: &Map[string, int]{safe: true, shards: []*shard[string, int]{{mutex: new(sync.RWMutex), data: map[string]entry[int]{}}}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := NewMap[string, int](tt.args.safe...); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("NewMap() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNewMapWith(t *testing.T) {
+    tests := []struct {
+        name       string
+        opts       []Option
+        wantSafe   bool
+        wantShards int
+    }{
+        {name: "default", opts: nil, wantSafe: false, wantShards: 1},
+        {name: "safe", opts: []Option{WithSafe()}, wantSafe: true, wantShards: 1},
+        {name: "shards", opts: []Option{WithShards(16)}, wantSafe: true, wantShards: 16},
+        {name: "shards round up", opts: []Option{WithShards(10)}, wantSafe: true, wantShards: 16},
+        {name: "shards invalid", opts: []Option{WithShards(0)}, wantSafe: false, wantShards: 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := NewMapWith[string, int](tt.opts...)
+            if got.safe != tt.wantSafe || len(got.shards) != tt.wantShards {
+                t.Errorf("NewMapWith() safe = %v, shards = %v, want %v, %v", got.safe, len(got.shards), tt.wantSafe, tt.wantShards)
+            }
+        })
+    }
+}
+
+func TestMap_sharded(t *testing.T) {
+    m := NewMapWith[int, int](WithShards(8))
+    for i := 0; i < 1000; i++ {
+        if err := m.Set(i, i*2); err != nil {
+            t.Fatalf("Set() error = %v", err)
+        }
+    }
+    if got := m.Size(); got != 1000 {
+        t.Errorf("Size() = %v, want %v", got, 1000)
+    }
+    if got := len(m.Keys()); got != 1000 {
+        t.Errorf("Keys() len = %v, want %v", got, 1000)
+    }
+    if got := len(m.All()); got != 1000 {
+        t.Errorf("All() len = %v, want %v", got, 1000)
+    }
+    for i := 0; i < 1000; i++ {
+        if v, ok, err := m.Get(i); !ok || err != nil || v != i*2 {
+            t.Fatalf("Get(%v) = %v, %v, %v", i, v, ok, err)
+        }
+    }
+    if err := m.Remove(1, 2, 3); err != nil || m.Has(1) || m.Has(2) || m.Has(3) || !m.Has(4) {
+        t.Errorf("Remove() error = %v", err)
+    }
+    if got := m.Size(); got != 997 {
+        t.Errorf("Size() = %v, want %v", got, 997)
+    }
+}
+
+func TestMap_sharded_concurrent(t *testing.T) {
+    m := NewMapWith[int, int](WithShards(4))
+    var wg sync.WaitGroup
+    for g := 0; g < 8; g++ {
+        wg.Add(1)
+        go func(g int) {
+            defer wg.Done()
+            for i := 0; i < 500; i++ {
+                m.Set(g*1000+i, i)
+                m.Get(g*1000 + i)
+            }
+        }(g)
+    }
+    wg.Wait()
+    if got := m.Size(); got != 4000 {
+        t.Errorf("Size() = %v, want %v", got, 4000)
+    }
+}
+
+func TestMap_copyOnWrite(t *testing.T) {
+    m := NewMapWith[string, int](WithCopyOnWrite(), WithShards(8))
+    if !m.safe || len(m.shards) != 1 {
+        t.Fatalf("NewMapWith() safe = %v, shards = %v, want true, 1", m.safe, len(m.shards))
+    }
+    m.Set("a", 1)
+    before := m.All()
+    m.Set("b", 2)
+    m.Remove("a")
+    if !reflect.DeepEqual(before, map[string]int{"a": 1}) {
+        t.Errorf("published data changed after write: %v", before)
+    }
+    if got := m.All(); !reflect.DeepEqual(got, map[string]int{"b": 2}) {
+        t.Errorf("All() = %v, want %v", got, map[string]int{"b": 2})
+    }
+    if v, ok, err := m.Get("b"); v != 2 || !ok || err != nil {
+        t.Errorf("Get() = %v, %v, %v", v, ok, err)
+    }
+    if m.Has("a") || m.Size() != 1 {
+        t.Errorf("Has() = %v, Size() = %v", m.Has("a"), m.Size())
+    }
+}
+
+func TestMap_copyOnWrite_concurrent(t *testing.T) {
+    m := NewMapWith[int, int](WithCopyOnWrite())
+    var wg sync.WaitGroup
+    for g := 0; g < 4; g++ {
+        wg.Add(2)
+        go func(g int) {
+            defer wg.Done()
+            for i := 0; i < 200; i++ {
+                m.Set(g*1000+i, i)
+            }
+        }(g)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < 200; i++ {
+                m.Get(i)
+                m.Keys()
+            }
+        }()
+    }
+    wg.Wait()
+    if got := m.Size(); got != 800 {
+        t.Errorf("Size() = %v, want %v", got, 800)
+    }
+}
+
+func TestMap_Get(t *testing.T) {
+    data := map[string]int{"a": 1, "b": 2}
+    tests := []struct {
+        name    string
+        a       *Map[string, int]
+        key     string
+        wantVal int
+        wantOk  bool
+    }{
+        {name: "unsafe not found", a: getMap(data), key: "c", wantVal: 0, wantOk: false},
+        {name: "unsafe found", a: getMap(data), key: "a", wantVal: 1, wantOk: true},
+        {name: "safe not found", a: getMap(data, true), key: "c", wantVal: 0, wantOk: false},
+        {name: "safe found", a: getMap(data, true), key: "b", wantVal: 2, wantOk: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gotVal, gotOk, err := tt.a.Get(tt.key)
+            if err != nil {
+                t.Errorf("Get() error = %v", err)
+                return
+            }
+            if gotVal != tt.wantVal || gotOk != tt.wantOk {
+                t.Errorf("Get() = %v, %v, want %v, %v", gotVal, gotOk, tt.wantVal, tt.wantOk)
+            }
+        })
+    }
+}
+
+func TestMap_Keys(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *Map[int, string]
+        want []int
+    }{
+        {name: "unsafe empty", a: getMap[int, string](nil), want: []int{}},
+        {name: "unsafe not empty", a: getMap(map[int]string{1: "1", 2: "2"}), want: []int{1, 2}},
+        {name: "safe empty", a: getMap[int, string](nil, true), want: []int{}},
+        {name: "safe not empty", a: getMap(map[int]string{1: "1", 2: "2"}, true), want: []int{1, 2}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := tt.a.Keys()
+            sort.Ints(got)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Keys() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMap_All(t *testing.T) {
+    data := map[string][]int{"a": {1}, "b": {2, 3}}
+    tests := []struct {
+        name string
+        a    *Map[string, []int]
+        want map[string][]int
+    }{
+        {name: "unsafe", a: getMap(data), want: data},
+        {name: "safe", a: getMap(data, true), want: data},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := tt.a.All(); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("All() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func BenchmarkMap_Set_safe(b *testing.B) {
+    m := NewMap[string, string](true)
+    for i := 0; i < b.N; i++ {
+        m.Set("test", "test")
+    }
+}
+
+func BenchmarkMap_Get_safe(b *testing.B) {
+    m := NewMap[string, string](true)
+    m.Set("test", "test")
+    for i := 0; i < b.N; i++ {
+        m.Get("test")
+    }
+}
+
+func BenchmarkMap_Get_safe_parallel(b *testing.B) {
+    m := NewMap[int, int](true)
+    for i := 0; i < 1024; i++ {
+        m.Set(i, i)
+    }
+    b.RunParallel(func(pb *testing.PB) {
+        i := 0
+        for pb.Next() {
+            m.Get(i & 1023)
+            i++
+        }
+    })
+}
+
+func BenchmarkMap_Get_cow_parallel(b *testing.B) {
+    m := NewMapWith[int, int](WithCopyOnWrite())
+    for i := 0; i < 1024; i++ {
+        m.Set(i, i)
+    }
+    b.RunParallel(func(pb *testing.PB) {
+        i := 0
+        for pb.Next() {
+            m.Get(i & 1023)
+            i++
+        }
+    })
+}
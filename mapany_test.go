@@ -14,6 +14,14 @@ func getMapAny(data map[interface{}]interface{}, safe ...bool) *MapAny {
     return m
 }
 
+func getMapAnySharded(data map[interface{}]interface{}) *MapAny {
+    m := NewMapAnyWith(WithShards(8))
+    for i, i2 := range data {
+        m.Set(i, i2)
+    }
+    return m
+}
+
 func TestNewMapAny(t *testing.T) {
     type args struct {
         safe []bool
@@ -23,8 +31,8 @@ func TestNewMapAny(t *testing.T) {
         args args
         want *MapAny
     }{
-        {name: "unsafe", want: &MapAny{safe: false, data: map[interface{}]interface{}{}}},
-        {name: "safe", args: args{safe: []bool{true}}, want: &MapAny{safe: true, mutex: new(sync.RWMutex), data: map[interface{}]interface{}{}}},
+        {name: "unsafe", want: &MapAny{safe: false, shards: []*shard[interface{}, interface{}]{{data: map[interface{}]entry[interface{}]{}}}}},
+        {name: "safe", args: args{safe: []bool{true}}, want: &MapAny{safe: true, shards: []*shard[interface{}, interface{}]{{mutex: new(sync.RWMutex), data: map[interface{}]entry[interface{}]{}}}}},
     }
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
@@ -75,6 +83,8 @@ func TestMapAny_Get(t *testing.T) {
         {name: "safe not found", a: getMapAny(data, true), args: args{"a"}, wantVal: nil, wantOk: false, wantErr: false},
         {name: "safe found", a: getMapAny(data, true), args: args{"test"}, wantVal: 1, wantOk: true, wantErr: false},
         {name: "safe error", a: getMapAny(data, true), args: args{[]string{"test"}}, wantVal: nil, wantOk: false, wantErr: true},
+        {name: "sharded found", a: getMapAnySharded(data), args: args{"test"}, wantVal: 1, wantOk: true, wantErr: false},
+        {name: "sharded error", a: getMapAnySharded(data), args: args{[]string{"test"}}, wantVal: nil, wantOk: false, wantErr: true},
     }
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
@@ -112,6 +122,8 @@ func TestMapAny_Has(t *testing.T) {
         {name: "safe not exits", a: getMapAny(nil, true), args: args{"a"}, wantB: false},
         {name: "safe exits", a: getMapAny(data, true), args: args{"test"}, wantB: true},
         {name: "safe error", a: getMapAny(data, true), args: args{[]string{"test"}}, wantB: false},
+        {name: "sharded exits", a: getMapAnySharded(data), args: args{"test"}, wantB: true},
+        {name: "sharded error", a: getMapAnySharded(data), args: args{[]string{"test"}}, wantB: false},
     }
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
@@ -194,6 +206,8 @@ func TestMapAny_Set(t *testing.T) {
         {name: "safe one", a: getMapAny(map[interface{}]interface{}{}, true), args: args{"test", 1}, wantErr: false},
         {name: "safe two", a: getMapAny(map[interface{}]interface{}{}, true), args: args{"test", []string{"test"}}, wantErr: false},
         {name: "safe three", a: getMapAny(map[interface{}]interface{}{}, true), args: args{[]string{"test"}, []string{"test"}}, wantErr: true},
+        {name: "sharded one", a: getMapAnySharded(map[interface{}]interface{}{}), args: args{"test", 1}, wantErr: false},
+        {name: "sharded three", a: getMapAnySharded(map[interface{}]interface{}{}), args: args{[]string{"test"}, []string{"test"}}, wantErr: true},
     }
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
@@ -255,6 +269,28 @@ func BenchmarkMapAny_Set_safe(b *testing.B) {
     }
 }
 
+func BenchmarkMapAny_Set_safe_parallel(b *testing.B) {
+    m := NewMapAny(true)
+    b.RunParallel(func(pb *testing.PB) {
+        i := 0
+        for pb.Next() {
+            m.Set(i&1023, i)
+            i++
+        }
+    })
+}
+
+func BenchmarkMapAny_Set_sharded_parallel(b *testing.B) {
+    m := NewMapAnyWith(WithShards(32))
+    b.RunParallel(func(pb *testing.PB) {
+        i := 0
+        for pb.Next() {
+            m.Set(i&1023, i)
+            i++
+        }
+    })
+}
+
 func BenchmarkMapAny_Get_unsafe(b *testing.B) {
     m := NewMapAny()
     m.Set("test", "test")
@@ -23,8 +23,8 @@ func TestNewMapStrAny(t *testing.T) {
         args args
         want *MapStrAny
     }{
-        {name: "unsafe", want: &MapStrAny{any: &MapAny{safe: false, data: map[interface{}]interface{}{}}}, args: args{}},
-        {name: "safe", want: &MapStrAny{any: NewMapAny(true)}, args: args{[]bool{true}}},
+        {name: "unsafe", want: &MapStrAny{strAnyMap: &strAnyMap{safe: false, shards: []*shard[string, interface{}]{{data: map[string]entry[interface{}]{}}}}}, args: args{}},
+        {name: "safe", want: &MapStrAny{strAnyMap: NewMap[string, interface{}](true)}, args: args{[]bool{true}}},
     }
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
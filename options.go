@@ -0,0 +1,223 @@
+package smap
+
+import (
+    "fmt"
+    "time"
+)
+
+// Option Map的配置项
+type Option func(*options)
+
+// options Map的配置集合
+type options struct {
+    safe        bool
+    shards      int
+    cow         bool
+    ttl         time.Duration
+    janitor     time.Duration
+    capacity    int
+    maxCost     int64
+    policy      interface{}
+    onEvict     interface{}
+    loader      interface{}
+    negativeTTL time.Duration
+    // store 持久化存储相关配置
+    store         interface{}
+    writeMode     WriteMode
+    flushInterval time.Duration
+    flushBatch    int
+    onStoreError  interface{}
+    // pathSep MapStrAny路径访问的分隔符
+    pathSep rune
+    // sortedJSON 编码JSON时按key排序
+    sortedJSON bool
+    // wal 预写日志相关配置
+    syncPolicy   SyncPolicy
+    syncInterval time.Duration
+    compactSize  int64
+}
+
+// newOptions 应用配置项并返回最终配置
+func newOptions(opts ...Option) *options {
+    o := &options{shards: 1}
+    for _, opt := range opts {
+        opt(o)
+    }
+    if o.shards < 1 {
+        o.shards = 1
+    }
+    if o.cow {
+        o.shards = 1
+    }
+    if o.shards > 1 || o.cow || o.janitor > 0 {
+        o.safe = true
+    }
+    return o
+}
+
+// assertOption 把配置项中保存的泛型值转换为Map对应的类型，类型不匹配时panic
+func assertOption[T any](name string, v interface{}) T {
+    t, ok := v.(T)
+    if !ok {
+        panic(fmt.Sprintf("smap: %s type %T does not match %T", name, v, *new(T)))
+    }
+    return t
+}
+
+// WithSafe 开启并发安全锁
+func WithSafe() Option {
+    return func(o *options) {
+        o.safe = true
+    }
+}
+
+// WithShards 开启分片模式，数据按key的哈希值分散到n个独立加锁的分片中
+// n会向上取整为2的幂，分片模式总是并发安全的
+func WithShards(n int) Option {
+    return func(o *options) {
+        size := 1
+        for size < n {
+            size <<= 1
+        }
+        o.shards = size
+    }
+}
+
+// WithCopyOnWrite 开启写时复制模式，适用于读远多于写的场景
+// 读操作读取原子发布的不可变数据，不加锁也不会被阻塞；写操作复制整份数据后再发布
+// 写时复制模式总是并发安全的，并且不使用分片
+func WithCopyOnWrite() Option {
+    return func(o *options) {
+        o.cow = true
+    }
+}
+
+// WithTTL 设置默认过期时间，Set等未指定过期时间的写操作都使用该值
+func WithTTL(ttl time.Duration) Option {
+    return func(o *options) {
+        o.ttl = ttl
+    }
+}
+
+// WithJanitor 开启后台清理协程，每隔interval清理一次已过期的数据
+// 清理协程与调用方并发访问数据，因此开启后map总是并发安全的，不再使用时需调用Close
+func WithJanitor(interval time.Duration) Option {
+    return func(o *options) {
+        o.janitor = interval
+    }
+}
+
+// WithCapacity 限制map的容量，超出容量时按淘汰策略淘汰数据，默认淘汰最近最少使用的数据
+// 分片模式下容量平均分配到各分片，每个分片独立淘汰
+// 开启后Get需要更新访问顺序因此会加写锁；写时复制模式下读操作不更新访问顺序
+func WithCapacity(n int) Option {
+    return func(o *options) {
+        o.capacity = n
+    }
+}
+
+// WithEvictCallback 设置数据因容量或过期被淘汰时的回调
+// fn的K、V类型需与Map一致，回调在释放锁之后执行
+func WithEvictCallback[K comparable, V any](fn func(key K, value V, reason EvictReason)) Option {
+    return func(o *options) {
+        o.onEvict = fn
+    }
+}
+
+// WithMaxCost 限制map中所有数据的成本之和，超出预算时按淘汰策略淘汰数据
+// 数据的成本通过SetWithCost指定，分片模式下预算平均分配到各分片
+func WithMaxCost(budget int64) Option {
+    return func(o *options) {
+        o.maxCost = budget
+    }
+}
+
+// WithEvictionPolicy 指定淘汰策略，factory为每个分片创建一个策略实例
+// 内置策略有NewLRUPolicy、NewLFUPolicy、New2QPolicy、NewCostPolicy，例如 WithEvictionPolicy(NewLFUPolicy[string])
+func WithEvictionPolicy[K comparable](factory func() EvictionPolicy[K]) Option {
+    return func(o *options) {
+        o.policy = factory
+    }
+}
+
+// WithLoader 设置默认加载函数，GetOrLoad未传入loader时使用
+// fn的K、V类型需与Map一致
+func WithLoader[K comparable, V any](fn func(key K) (V, error)) Option {
+    return func(o *options) {
+        o.loader = fn
+    }
+}
+
+// WithNegativeTTL 缓存加载失败的结果，ttl内再次GetOrLoad直接返回缓存的error而不调用loader
+func WithNegativeTTL(ttl time.Duration) Option {
+    return func(o *options) {
+        o.negativeTTL = ttl
+    }
+}
+
+// WithStore 设置持久化存储，写操作按mode同步写入或延迟写入store
+// 同步写入在持有分片锁时调用store，保证store与内存中的修改顺序一致
+// 未配置WithLoader时GetOrLoad从store加载数据；因容量、成本或过期被淘汰的数据不会从store中删除
+// 延迟写入模式下不再使用时需调用Close把尚未写入的修改写入store，关闭后的写操作会返回error
+func WithStore[K comparable, V any](store Store[K, V], mode WriteMode) Option {
+    return func(o *options) {
+        o.store = store
+        o.writeMode = mode
+    }
+}
+
+// WithFlushInterval 设置延迟写入的写入间隔，默认为1秒
+func WithFlushInterval(interval time.Duration) Option {
+    return func(o *options) {
+        o.flushInterval = interval
+    }
+}
+
+// WithFlushBatch 延迟写入中待写入的key达到n个时立即写入，不必等到下一个写入间隔
+func WithFlushBatch(n int) Option {
+    return func(o *options) {
+        o.flushBatch = n
+    }
+}
+
+// WithStoreErrorHandler 设置延迟写入失败时的回调，fn的K类型需与Map一致
+func WithStoreErrorHandler[K comparable](fn func(key K, err error)) Option {
+    return func(o *options) {
+        o.onStoreError = fn
+    }
+}
+
+// WithPathSeparator 设置MapStrAny路径访问的分隔符，默认为'.'，只对MapStrAny生效
+func WithPathSeparator(sep rune) Option {
+    return func(o *options) {
+        o.pathSep = sep
+    }
+}
+
+// WithSortedJSON 编码JSON时按key排序输出，默认按遍历顺序输出以避免复制和排序
+func WithSortedJSON() Option {
+    return func(o *options) {
+        o.sortedJSON = true
+    }
+}
+
+// WithSyncPolicy 设置预写日志的同步策略，默认为SyncInterval，只对OpenMap打开的map生效
+func WithSyncPolicy(policy SyncPolicy) Option {
+    return func(o *options) {
+        o.syncPolicy = policy
+    }
+}
+
+// WithSyncInterval 设置SyncInterval策略下预写日志的同步间隔，默认为1秒
+func WithSyncInterval(interval time.Duration) Option {
+    return func(o *options) {
+        o.syncInterval = interval
+    }
+}
+
+// WithCompactSize 预写日志写入的字节数达到n时在后台压缩日志，默认为64MB，n<0表示不自动压缩
+func WithCompactSize(n int64) Option {
+    return func(o *options) {
+        o.compactSize = n
+    }
+}
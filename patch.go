@@ -0,0 +1,467 @@
+package smap
+
+import (
+    "encoding/json"
+    "fmt"
+    "maps"
+    "reflect"
+    "slices"
+    "sort"
+)
+
+// PatchOp JSON Patch(RFC 6902)中的一个操作
+type PatchOp struct {
+    // Op 操作类型：add、remove、replace、move、copy、test
+    Op    string      `json:"op"`
+    Path  string      `json:"path"`
+    From  string      `json:"from,omitempty"`
+    Value interface{} `json:"value"`
+}
+
+// needsValue 操作是否必须包含value成员
+func (op PatchOp) needsValue() bool {
+    return op.Op == "add" || op.Op == "replace" || op.Op == "test"
+}
+
+// MarshalJSON 实现json.Marshaler，add、replace、test操作总是输出value（包括null），其他操作value为nil时省略
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+    out := struct {
+        Op    string       `json:"op"`
+        Path  string       `json:"path"`
+        From  string       `json:"from,omitempty"`
+        Value *interface{} `json:"value,omitempty"`
+    }{Op: op.Op, Path: op.Path, From: op.From}
+    if op.needsValue() || op.Value != nil {
+        out.Value = &op.Value
+    }
+    return json.Marshal(out)
+}
+
+// UnmarshalJSON 实现json.Unmarshaler，add、replace、test操作缺少value成员时返回error
+func (op *PatchOp) UnmarshalJSON(data []byte) error {
+    var in struct {
+        Op    string          `json:"op"`
+        Path  string          `json:"path"`
+        From  string          `json:"from"`
+        Value json.RawMessage `json:"value"`
+    }
+    if err := json.Unmarshal(data, &in); err != nil {
+        return err
+    }
+    *op = PatchOp{Op: in.Op, Path: in.Path, From: in.From}
+    if in.Value == nil {
+        if op.needsValue() {
+            return fmt.Errorf("smap: patch operation %s %s: missing value", op.Op, op.Path)
+        }
+        return nil
+    }
+    return json.Unmarshal(in.Value, &op.Value)
+}
+
+// Patch JSON Patch文档
+type Patch []PatchOp
+
+// patchDoc 应用补丁时的文档，顶层key的读写通过事务完成
+type patchDoc struct {
+    tx *Tx[string, interface{}]
+}
+
+// ApplyPatch 原子地应用JSON Patch(RFC 6902)，任意操作失败时所有修改都不生效
+// 补丁期间持有所有分片的写锁，路径经过的嵌套map和切片会被复制后修改
+// 路径不能穿过嵌套的MapStrAny修改数据，因为其修改无法随事务回滚
+func (a *MapStrAny) ApplyPatch(patch Patch) error {
+    return a.Txn(func(tx *Tx[string, interface{}]) error {
+        doc := patchDoc{tx: tx}
+        for i, op := range patch {
+            if err := doc.apply(op); err != nil {
+                return fmt.Errorf("smap: patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+            }
+        }
+        return nil
+    })
+}
+
+// ApplyPatchJSON 解析并原子地应用JSON Patch文档
+func (a *MapStrAny) ApplyPatchJSON(data []byte) error {
+    var patch Patch
+    if err := json.Unmarshal(data, &patch); err != nil {
+        return err
+    }
+    return a.ApplyPatch(patch)
+}
+
+// ApplyMergePatch 原子地应用JSON Merge Patch(RFC 7386)，值为nil的key会被删除
+func (a *MapStrAny) ApplyMergePatch(patch map[string]interface{}) error {
+    return a.Txn(func(tx *Tx[string, interface{}]) error {
+        for _, key := range sortedKeys(patch) {
+            if patch[key] == nil {
+                tx.Remove(key)
+                continue
+            }
+            old, _, _ := tx.Get(key)
+            nv, err := mergePatch(old, patch[key])
+            if err != nil {
+                return fmt.Errorf("smap: merge patch %q: %w", key, err)
+            }
+            tx.Set(key, nv)
+        }
+        return nil
+    })
+}
+
+// ApplyMergePatchJSON 解析并原子地应用JSON Merge Patch文档，文档必须是对象
+func (a *MapStrAny) ApplyMergePatchJSON(data []byte) error {
+    var patch map[string]interface{}
+    if err := json.Unmarshal(data, &patch); err != nil {
+        return err
+    }
+    return a.ApplyMergePatch(patch)
+}
+
+// Diff 生成把a修改为b的JSON Patch
+// 嵌套的对象逐个key比较，长度相同的数组逐个元素比较，长度不同时整体替换
+func Diff(a, b *MapStrAny) Patch {
+    patch := make(Patch, 0)
+    return diffValue(patch, nil, a.All(), b.All())
+}
+
+// apply 执行单个补丁操作
+func (d patchDoc) apply(op PatchOp) error {
+    path, err := parsePointer(op.Path)
+    if err != nil {
+        return err
+    }
+    switch op.Op {
+    case "add":
+        return d.add(path, op.Value)
+    case "remove":
+        return d.remove(path)
+    case "replace":
+        if _, ok := d.get(path); !ok {
+            return fmt.Errorf("path not found")
+        }
+        if len(path) > 0 {
+            if err := d.remove(path); err != nil {
+                return err
+            }
+        }
+        return d.add(path, op.Value)
+    case "move", "copy":
+        from, err := parsePointer(op.From)
+        if err != nil {
+            return err
+        }
+        value, ok := d.get(from)
+        if !ok {
+            return fmt.Errorf("from %q not found", op.From)
+        }
+        if op.Op == "copy" {
+            return d.add(path, deepCopy(value))
+        }
+        if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+            return fmt.Errorf("cannot move %q into its own child", op.From)
+        }
+        if err := d.remove(from); err != nil {
+            return err
+        }
+        return d.add(path, value)
+    case "test":
+        value, ok := d.get(path)
+        if !ok {
+            return fmt.Errorf("path not found")
+        }
+        if !jsonEqual(value, op.Value) {
+            return fmt.Errorf("test failed: %v != %v", value, op.Value)
+        }
+        return nil
+    }
+    return fmt.Errorf("unknown op %q", op.Op)
+}
+
+// root 获取整个文档
+func (d patchDoc) root() map[string]interface{} {
+    doc := make(map[string]interface{})
+    for _, key := range d.tx.Keys() {
+        doc[key], _, _ = d.tx.Get(key)
+    }
+    return doc
+}
+
+// get 按路径获取value
+func (d patchDoc) get(path []string) (interface{}, bool) {
+    if len(path) == 0 {
+        return d.root(), true
+    }
+    val, ok, _ := d.tx.Get(path[0])
+    for _, token := range path[1:] {
+        if !ok {
+            return nil, false
+        }
+        val, ok = pointerChild(val, token)
+    }
+    return val, ok
+}
+
+// add 按路径添加value，路径为空时用对象value替换整个文档
+func (d patchDoc) add(path []string, value interface{}) error {
+    if len(path) == 0 {
+        obj, ok := value.(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("document root must be an object, got %T", value)
+        }
+        d.tx.Remove(d.tx.Keys()...)
+        for k, v := range obj {
+            d.tx.Set(k, v)
+        }
+        return nil
+    }
+    if len(path) == 1 {
+        return d.tx.Set(path[0], value)
+    }
+    old, ok, _ := d.tx.Get(path[0])
+    if !ok {
+        return fmt.Errorf("path not found")
+    }
+    nv, err := patchAdd(old, path[1:], value)
+    if err != nil {
+        return err
+    }
+    return d.tx.Set(path[0], nv)
+}
+
+// remove 按路径删除value
+func (d patchDoc) remove(path []string) error {
+    if len(path) == 0 {
+        return fmt.Errorf("cannot remove document root")
+    }
+    if !d.tx.Has(path[0]) {
+        return fmt.Errorf("path not found")
+    }
+    if len(path) == 1 {
+        return d.tx.Remove(path[0])
+    }
+    old, _, _ := d.tx.Get(path[0])
+    nv, err := patchRemove(old, path[1:])
+    if err != nil {
+        return err
+    }
+    return d.tx.Set(path[0], nv)
+}
+
+// patchAdd 返回在容器doc的path处添加了value的副本，数组的"-"表示追加
+func patchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+    token, rest := path[0], path[1:]
+    switch c := doc.(type) {
+    case map[string]interface{}:
+        m := maps.Clone(c)
+        if len(rest) == 0 {
+            m[token] = value
+            return m, nil
+        }
+        child, ok := c[token]
+        if !ok {
+            return nil, fmt.Errorf("path not found")
+        }
+        nv, err := patchAdd(child, rest, value)
+        m[token] = nv
+        return m, err
+    case []interface{}:
+        if len(rest) == 0 && token == "-" {
+            return append(slices.Clone(c), value), nil
+        }
+        i, ok := pointerIndex(token)
+        if !ok || i > len(c) || (len(rest) > 0 && i == len(c)) {
+            return nil, fmt.Errorf("bad array index %q", token)
+        }
+        if len(rest) == 0 {
+            return slices.Insert(slices.Clone(c), i, value), nil
+        }
+        s := slices.Clone(c)
+        nv, err := patchAdd(c[i], rest, value)
+        s[i] = nv
+        return s, err
+    }
+    return nil, unpatchable(doc)
+}
+
+// patchRemove 返回删除了容器doc中path的副本
+func patchRemove(doc interface{}, path []string) (interface{}, error) {
+    token, rest := path[0], path[1:]
+    switch c := doc.(type) {
+    case map[string]interface{}:
+        child, ok := c[token]
+        if !ok {
+            return nil, fmt.Errorf("path not found")
+        }
+        m := maps.Clone(c)
+        if len(rest) == 0 {
+            delete(m, token)
+            return m, nil
+        }
+        nv, err := patchRemove(child, rest)
+        m[token] = nv
+        return m, err
+    case []interface{}:
+        i, ok := pointerIndex(token)
+        if !ok || i >= len(c) {
+            return nil, fmt.Errorf("bad array index %q", token)
+        }
+        if len(rest) == 0 {
+            return slices.Delete(slices.Clone(c), i, i+1), nil
+        }
+        s := slices.Clone(c)
+        nv, err := patchRemove(c[i], rest)
+        s[i] = nv
+        return s, err
+    }
+    return nil, unpatchable(doc)
+}
+
+// unpatchable 无法修改的容器类型的错误
+func unpatchable(doc interface{}) error {
+    if _, ok := doc.(*MapStrAny); ok {
+        return fmt.Errorf("cannot patch through nested *MapStrAny atomically")
+    }
+    if doc == nil {
+        return fmt.Errorf("path not found")
+    }
+    return fmt.Errorf("cannot patch through %T", doc)
+}
+
+// mergePatch 按RFC 7386合并patch到target，返回合并后的副本
+func mergePatch(target, patch interface{}) (interface{}, error) {
+    obj, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch, nil
+    }
+    var m map[string]interface{}
+    switch t := target.(type) {
+    case map[string]interface{}:
+        m = maps.Clone(t)
+    case *MapStrAny:
+        return nil, unpatchable(t)
+    default:
+        m = make(map[string]interface{})
+    }
+    for _, key := range sortedKeys(obj) {
+        if obj[key] == nil {
+            delete(m, key)
+            continue
+        }
+        nv, err := mergePatch(m[key], obj[key])
+        if err != nil {
+            return nil, err
+        }
+        m[key] = nv
+    }
+    return m, nil
+}
+
+// diffValue 比较a和b并把差异追加到patch，path为当前路径
+func diffValue(patch Patch, path []interface{}, a, b interface{}) Patch {
+    if jsonEqual(a, b) {
+        return patch
+    }
+    ptr := formatPointer(path)
+    ka, aObj := asObject(a)
+    kb, bObj := asObject(b)
+    if aObj && bObj {
+        for _, key := range sortedKeys(ka) {
+            if _, ok := kb[key]; !ok {
+                patch = append(patch, PatchOp{Op: "remove", Path: formatPointer(append(slices.Clone(path), key))})
+            }
+        }
+        for _, key := range sortedKeys(kb) {
+            child := append(slices.Clone(path), key)
+            if va, ok := ka[key]; ok {
+                patch = diffValue(patch, child, va, kb[key])
+            } else {
+                patch = append(patch, PatchOp{Op: "add", Path: formatPointer(child), Value: kb[key]})
+            }
+        }
+        return patch
+    }
+    la, aList := a.([]interface{})
+    lb, bList := b.([]interface{})
+    if aList && bList && len(la) == len(lb) {
+        for i := range la {
+            patch = diffValue(patch, append(slices.Clone(path), i), la[i], lb[i])
+        }
+        return patch
+    }
+    return append(patch, PatchOp{Op: "replace", Path: ptr, Value: b})
+}
+
+// asObject 把对象类型转换为map[string]interface{}
+func asObject(v interface{}) (map[string]interface{}, bool) {
+    switch c := v.(type) {
+    case map[string]interface{}:
+        return c, true
+    case *MapStrAny:
+        return c.All(), true
+    }
+    return nil, false
+}
+
+// jsonEqual 按JSON语义比较两个值，数值按大小比较
+func jsonEqual(a, b interface{}) bool {
+    if fa, ok := number(a); ok {
+        fb, ok := number(b)
+        return ok && fa == fb
+    }
+    if oa, ok := asObject(a); ok {
+        ob, ok := asObject(b)
+        if !ok || len(oa) != len(ob) {
+            return false
+        }
+        for k, va := range oa {
+            vb, ok := ob[k]
+            if !ok || !jsonEqual(va, vb) {
+                return false
+            }
+        }
+        return true
+    }
+    if la, ok := a.([]interface{}); ok {
+        lb, ok := b.([]interface{})
+        if !ok || len(la) != len(lb) {
+            return false
+        }
+        for i := range la {
+            if !jsonEqual(la[i], lb[i]) {
+                return false
+            }
+        }
+        return true
+    }
+    return reflect.DeepEqual(a, b)
+}
+
+// deepCopy 深度复制嵌套的map和切片
+func deepCopy(v interface{}) interface{} {
+    switch c := v.(type) {
+    case map[string]interface{}:
+        m := make(map[string]interface{}, len(c))
+        for k, v := range c {
+            m[k] = deepCopy(v)
+        }
+        return m
+    case []interface{}:
+        s := make([]interface{}, len(c))
+        for i, v := range c {
+            s[i] = deepCopy(v)
+        }
+        return s
+    }
+    return v
+}
+
+// sortedKeys 获取排序后的所有key
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
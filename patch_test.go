@@ -0,0 +1,175 @@
+package smap
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+// getPatchMap 使用JSON文档创建MapStrAny
+func getPatchMap(t *testing.T, doc string) *MapStrAny {
+    var data map[string]interface{}
+    if err := json.Unmarshal([]byte(doc), &data); err != nil {
+        t.Fatalf("Unmarshal() error = %v", err)
+    }
+    return getMapStrAny(data, true)
+}
+
+// decodeJSON 解析JSON文档
+func decodeJSON(t *testing.T, doc string) map[string]interface{} {
+    var data map[string]interface{}
+    if err := json.Unmarshal([]byte(doc), &data); err != nil {
+        t.Fatalf("Unmarshal() error = %v", err)
+    }
+    return data
+}
+
+func TestMapStrAny_ApplyPatch(t *testing.T) {
+    base := `{"a":{"b":[1,2,3]},"c":"x"}`
+    tests := []struct {
+        name    string
+        patch   string
+        want    string
+        wantErr bool
+    }{
+        {name: "add member", patch: `[{"op":"add","path":"/a/d","value":4}]`, want: `{"a":{"b":[1,2,3],"d":4},"c":"x"}`},
+        {name: "add top", patch: `[{"op":"add","path":"/e","value":null}]`, want: `{"a":{"b":[1,2,3]},"c":"x","e":null}`},
+        {name: "insert", patch: `[{"op":"add","path":"/a/b/1","value":9}]`, want: `{"a":{"b":[1,9,2,3]},"c":"x"}`},
+        {name: "append", patch: `[{"op":"add","path":"/a/b/-","value":9}]`, want: `{"a":{"b":[1,2,3,9]},"c":"x"}`},
+        {name: "remove", patch: `[{"op":"remove","path":"/a/b/0"},{"op":"remove","path":"/c"}]`, want: `{"a":{"b":[2,3]}}`},
+        {name: "replace", patch: `[{"op":"replace","path":"/a/b/2","value":"z"}]`, want: `{"a":{"b":[1,2,"z"]},"c":"x"}`},
+        {name: "replace root", patch: `[{"op":"replace","path":"","value":{"n":1}}]`, want: `{"n":1}`},
+        {name: "move", patch: `[{"op":"move","from":"/a/b","path":"/b"}]`, want: `{"a":{},"b":[1,2,3],"c":"x"}`},
+        {name: "copy", patch: `[{"op":"copy","from":"/a","path":"/a2"}]`, want: `{"a":{"b":[1,2,3]},"a2":{"b":[1,2,3]},"c":"x"}`},
+        {name: "test", patch: `[{"op":"test","path":"/a/b","value":[1,2,3]},{"op":"add","path":"/t","value":true}]`, want: `{"a":{"b":[1,2,3]},"c":"x","t":true}`},
+        {name: "test failed rolls back", patch: `[{"op":"add","path":"/t","value":true},{"op":"test","path":"/c","value":"y"}]`, want: base, wantErr: true},
+        {name: "missing parent", patch: `[{"op":"add","path":"/x/y","value":1}]`, want: base, wantErr: true},
+        {name: "remove missing", patch: `[{"op":"remove","path":"/a/x"}]`, want: base, wantErr: true},
+        {name: "bad index", patch: `[{"op":"add","path":"/a/b/5","value":1}]`, want: base, wantErr: true},
+        {name: "move into child", patch: `[{"op":"move","from":"/a","path":"/a/x"}]`, want: base, wantErr: true},
+        {name: "unknown op", patch: `[{"op":"noop","path":"/a"}]`, want: base, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := getPatchMap(t, base)
+            before, _, _ := m.Get("a")
+            if err := m.ApplyPatchJSON([]byte(tt.patch)); (err != nil) != tt.wantErr {
+                t.Fatalf("ApplyPatchJSON() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if got, want := m.All(), decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+                t.Errorf("ApplyPatchJSON() = %v, want %v", got, want)
+            }
+            if !reflect.DeepEqual(before, decodeJSON(t, base)["a"]) {
+                t.Errorf("ApplyPatchJSON() modified value shared with readers: %v", before)
+            }
+        })
+    }
+}
+
+func TestPatchOp_JSON(t *testing.T) {
+    tests := []struct {
+        name string
+        op   PatchOp
+        want string
+    }{
+        {name: "null value", op: PatchOp{Op: "replace", Path: "/x"}, want: `{"op":"replace","path":"/x","value":null}`},
+        {name: "test null", op: PatchOp{Op: "test", Path: "/x"}, want: `{"op":"test","path":"/x","value":null}`},
+        {name: "remove", op: PatchOp{Op: "remove", Path: "/x"}, want: `{"op":"remove","path":"/x"}`},
+        {name: "move", op: PatchOp{Op: "move", Path: "/x", From: "/y"}, want: `{"op":"move","path":"/x","from":"/y"}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, err := json.Marshal(tt.op)
+            if err != nil || string(data) != tt.want {
+                t.Fatalf("Marshal() = %s, %v, want %s", data, err, tt.want)
+            }
+            var got PatchOp
+            if err := json.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, tt.op) {
+                t.Errorf("Unmarshal() = %+v, %v, want %+v", got, err, tt.op)
+            }
+        })
+    }
+    for _, op := range []string{"add", "replace", "test"} {
+        m := NewMapStrAny()
+        if err := m.ApplyPatchJSON([]byte(`[{"op":"` + op + `","path":"/y"}]`)); err == nil || m.Has("y") {
+            t.Errorf("ApplyPatchJSON() %s without value error = %v", op, err)
+        }
+    }
+}
+
+func TestMapStrAny_ApplyPatchNested(t *testing.T) {
+    m := NewMapStrAny(true)
+    m.Set("inner", NewMapStrAny(true))
+    err := m.ApplyPatch(Patch{{Op: "add", Path: "/x", Value: 1}, {Op: "add", Path: "/inner/a", Value: 1}})
+    if err == nil || m.Has("x") {
+        t.Errorf("ApplyPatch() through nested MapStrAny error = %v, has x %v", err, m.Has("x"))
+    }
+}
+
+func TestMapStrAny_ApplyMergePatch(t *testing.T) {
+    tests := []struct {
+        name  string
+        base  string
+        patch string
+        want  string
+    }{
+        {name: "replace", base: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+        {name: "add", base: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+        {name: "remove", base: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+        {name: "array replaced", base: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+        {name: "nested", base: `{"e":null,"a":{"b":"c","d":"x"}}`, patch: `{"a":{"b":"z","d":null,"n":{"m":null,"k":1}}}`, want: `{"e":null,"a":{"b":"z","n":{"k":1}}}`},
+        {name: "scalar to object", base: `{"a":"foo"}`, patch: `{"a":{"b":"c"}}`, want: `{"a":{"b":"c"}}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := getPatchMap(t, tt.base)
+            if err := m.ApplyMergePatchJSON([]byte(tt.patch)); err != nil {
+                t.Fatalf("ApplyMergePatchJSON() error = %v", err)
+            }
+            if got, want := m.All(), decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+                t.Errorf("ApplyMergePatchJSON() = %v, want %v", got, want)
+            }
+        })
+    }
+    if err := NewMapStrAny().ApplyMergePatchJSON([]byte(`[1]`)); err == nil {
+        t.Errorf("ApplyMergePatchJSON() non-object error = nil")
+    }
+}
+
+func TestDiff(t *testing.T) {
+    tests := []struct {
+        name string
+        a    string
+        b    string
+        want Patch
+    }{
+        {name: "equal", a: `{"a":1}`, b: `{"a":1}`, want: Patch{}},
+        {name: "members", a: `{"a":1,"b":2,"c":{"x":1}}`, b: `{"b":3,"c":{"x":1,"y":[1]},"d":true}`, want: Patch{
+            {Op: "remove", Path: "/a"},
+            {Op: "replace", Path: "/b", Value: float64(3)},
+            {Op: "add", Path: "/c/y", Value: []interface{}{float64(1)}},
+            {Op: "add", Path: "/d", Value: true},
+        }},
+        {name: "arrays", a: `{"l":[1,2],"m":[1]}`, b: `{"l":[1,3],"m":[1,2]}`, want: Patch{
+            {Op: "replace", Path: "/l/1", Value: float64(3)},
+            {Op: "replace", Path: "/m", Value: []interface{}{float64(1), float64(2)}},
+        }},
+        {name: "escaped key", a: `{"a/b":1}`, b: `{"a/b":2}`, want: Patch{{Op: "replace", Path: "/a~1b", Value: float64(2)}}},
+        {name: "null", a: `{"a":1}`, b: `{"a":null,"b":null}`, want: Patch{
+            {Op: "replace", Path: "/a", Value: nil},
+            {Op: "add", Path: "/b", Value: nil},
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            a, b := getPatchMap(t, tt.a), getPatchMap(t, tt.b)
+            got := Diff(a, b)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("Diff() = %+v, want %+v", got, tt.want)
+            }
+            if err := a.ApplyPatch(got); err != nil || !reflect.DeepEqual(a.All(), b.All()) {
+                t.Errorf("ApplyPatch(Diff()) = %v, %v, want %v", a.All(), err, b.All())
+            }
+        })
+    }
+}
@@ -0,0 +1,367 @@
+package smap
+
+import (
+    "errors"
+    "fmt"
+    "maps"
+    "reflect"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// errUnchanged 路径修改没有改变数据，用于跳过写入
+var errUnchanged = errors.New("smap: unchanged")
+
+// pathSeg 路径中的一段，isIndex表示使用[n]语法访问切片下标
+type pathSeg struct {
+    key     string
+    index   int
+    isIndex bool
+}
+
+// String 路径段的文本形式
+func (p pathSeg) String() string {
+    if p.isIndex {
+        return "[" + strconv.Itoa(p.index) + "]"
+    }
+    return p.key
+}
+
+// separator 路径分隔符
+func (a *MapStrAny) separator() rune {
+    if a.sep == 0 {
+        return '.'
+    }
+    return a.sep
+}
+
+// parsePath 解析路径，如"db.replicas[2].host"
+// 使用'\'转义分隔符、'['、']'和'\'本身，如"a\.b"表示key为"a.b"
+func parsePath(path string, sep rune) ([]pathSeg, error) {
+    segs := make([]pathSeg, 0)
+    var buf strings.Builder
+    pending, afterIndex := false, false
+    invalid := func(msg string) error {
+        return fmt.Errorf("smap: invalid path %q: %s", path, msg)
+    }
+    flush := func() {
+        if pending {
+            segs = append(segs, pathSeg{key: buf.String()})
+            buf.Reset()
+            pending = false
+        }
+    }
+    runes := []rune(path)
+    for i := 0; i < len(runes); i++ {
+        c := runes[i]
+        switch {
+        case c == '\\':
+            if i+1 == len(runes) {
+                return nil, invalid("trailing escape")
+            }
+            i++
+            buf.WriteRune(runes[i])
+            pending = true
+        case c == sep:
+            if !pending && !afterIndex {
+                return nil, invalid("empty key")
+            }
+            flush()
+            afterIndex = false
+            if i+1 == len(runes) {
+                return nil, invalid("empty key")
+            }
+        case c == '[':
+            if !pending && !afterIndex {
+                return nil, invalid("index without key")
+            }
+            flush()
+            end := i + 1
+            for end < len(runes) && runes[end] != ']' {
+                end++
+            }
+            if end == len(runes) {
+                return nil, invalid("unclosed '['")
+            }
+            n, err := strconv.Atoi(string(runes[i+1 : end]))
+            if err != nil || n < 0 {
+                return nil, invalid("bad index " + string(runes[i+1:end]))
+            }
+            segs = append(segs, pathSeg{index: n, isIndex: true})
+            afterIndex = true
+            i = end
+            if i+1 < len(runes) && runes[i+1] != sep && runes[i+1] != '[' {
+                return nil, invalid("unexpected character after index")
+            }
+        case c == ']':
+            return nil, invalid("unexpected ']'")
+        default:
+            buf.WriteRune(c)
+            pending = true
+        }
+    }
+    flush()
+    if len(segs) == 0 {
+        return nil, invalid("empty path")
+    }
+    return segs, nil
+}
+
+// sliceIndex 计算路径段在长度为n的切片中的下标，非[n]语法的数字key也视为下标
+func sliceIndex(seg pathSeg, n int) (int, bool) {
+    i := seg.index
+    if !seg.isIndex {
+        var err error
+        if i, err = strconv.Atoi(seg.key); err != nil || i < 0 {
+            return 0, false
+        }
+    }
+    return i, i >= 0 && i < n
+}
+
+// GetPath 按路径获取value，如GetPath("db.replicas[2].host")
+// 路径可以穿过嵌套的MapStrAny、map和切片
+func (a *MapStrAny) GetPath(path string) (val interface{}, ok bool, err error) {
+    segs, err := parsePath(path, a.separator())
+    if err != nil {
+        return nil, false, err
+    }
+    val, ok, err = a.Get(segs[0].key)
+    for _, seg := range segs[1:] {
+        if !ok || err != nil {
+            return nil, false, err
+        }
+        val, ok = getIn(val, seg)
+    }
+    if !ok {
+        return nil, false, nil
+    }
+
+    return val, true, err
+}
+
+// HasPath 检查路径是否存在
+func (a *MapStrAny) HasPath(path string) bool {
+    _, ok, _ := a.GetPath(path)
+    return ok
+}
+
+// SetPath 按路径设置value，不存在的中间节点会自动创建
+// 中间节点为map时复制后修改，为切片时下标可以等于切片长度以追加元素
+// 修改在路径第一段key所在分片的锁内完成，读取到旧的嵌套数据的调用方不受影响
+func (a *MapStrAny) SetPath(path string, value interface{}) (err error) {
+    segs, err := parsePath(path, a.separator())
+    if err != nil {
+        return err
+    }
+    if len(segs) == 1 {
+        return a.Set(segs[0].key, value)
+    }
+    return a.update(segs[0].key, func(old interface{}, exists bool) (interface{}, bool, error) {
+        nv, err := setIn(old, segs[1:], value)
+        return nv, true, err
+    })
+}
+
+// RemovePath 按路径删除value，路径不存在时不做任何修改
+// 删除切片元素时后面的元素前移
+func (a *MapStrAny) RemovePath(path string) (err error) {
+    segs, err := parsePath(path, a.separator())
+    if err != nil {
+        return err
+    }
+    if len(segs) == 1 {
+        return a.Remove(segs[0].key)
+    }
+    return a.update(segs[0].key, func(old interface{}, exists bool) (interface{}, bool, error) {
+        if !exists {
+            return nil, false, errUnchanged
+        }
+        return removeIn(old, segs[1:])
+    })
+}
+
+// update 在持有锁的情况下修改key，fn返回keep为false时删除key，返回errUnchanged时不做任何修改
+func (a *MapStrAny) update(key string, fn func(old interface{}, exists bool) (interface{}, bool, error)) (err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e, exists := lookup(s.current(), key, a.now())
+    nv, keep, err := fn(e.value, exists)
+    switch {
+    case errors.Is(err, errUnchanged):
+        return nil
+    case err != nil:
+        return err
+    case keep:
+        return a.storeLocked(s, key, a.newEntry(nv))
+    case exists:
+        return a.removeLocked(s, key)
+    }
+    return nil
+}
+
+// getIn 获取容器v中路径段seg对应的value
+func getIn(v interface{}, seg pathSeg) (interface{}, bool) {
+    switch c := v.(type) {
+    case *MapStrAny:
+        if seg.isIndex {
+            return nil, false
+        }
+        val, ok, _ := c.Get(seg.key)
+        return val, ok
+    case map[string]interface{}:
+        if seg.isIndex {
+            return nil, false
+        }
+        val, ok := c[seg.key]
+        return val, ok
+    case []interface{}:
+        i, ok := sliceIndex(seg, len(c))
+        if !ok {
+            return nil, false
+        }
+        return c[i], true
+    case nil:
+        return nil, false
+    }
+    rv := reflect.ValueOf(v)
+    switch rv.Kind() {
+    case reflect.Map:
+        if seg.isIndex || rv.Type().Key().Kind() != reflect.String {
+            return nil, false
+        }
+        mv := rv.MapIndex(reflect.ValueOf(seg.key).Convert(rv.Type().Key()))
+        if !mv.IsValid() {
+            return nil, false
+        }
+        return mv.Interface(), true
+    case reflect.Slice, reflect.Array:
+        i, ok := sliceIndex(seg, rv.Len())
+        if !ok {
+            return nil, false
+        }
+        return rv.Index(i).Interface(), true
+    }
+    return nil, false
+}
+
+// setIn 返回设置了路径segs的容器，map和切片会被复制后修改
+func setIn(v interface{}, segs []pathSeg, value interface{}) (interface{}, error) {
+    if len(segs) == 0 {
+        return value, nil
+    }
+    seg, rest := segs[0], segs[1:]
+    switch c := v.(type) {
+    case nil:
+        if seg.isIndex {
+            return setIn([]interface{}{}, segs, value)
+        }
+        return setIn(map[string]interface{}{}, segs, value)
+    case *MapStrAny:
+        if seg.isIndex {
+            break
+        }
+        return c, c.update(seg.key, func(old interface{}, exists bool) (interface{}, bool, error) {
+            nv, err := setIn(old, rest, value)
+            return nv, true, err
+        })
+    case map[string]interface{}:
+        if seg.isIndex {
+            break
+        }
+        nv, err := setIn(c[seg.key], rest, value)
+        if err != nil {
+            return nil, err
+        }
+        m := maps.Clone(c)
+        m[seg.key] = nv
+        return m, nil
+    case []interface{}:
+        i, ok := sliceIndex(seg, len(c))
+        if !ok && i != len(c) {
+            return nil, fmt.Errorf("smap: path %s: index out of range [0:%d]", seg, len(c))
+        }
+        var child interface{}
+        if ok {
+            child = c[i]
+        }
+        nv, err := setIn(child, rest, value)
+        if err != nil {
+            return nil, err
+        }
+        s := slices.Clone(c)
+        if ok {
+            s[i] = nv
+        } else {
+            s = append(s, nv)
+        }
+        return s, nil
+    }
+    return nil, fmt.Errorf("smap: path %s: cannot set through %T", seg, v)
+}
+
+// removeIn 返回删除了路径segs的容器，removed为false时返回errUnchanged
+func removeIn(v interface{}, segs []pathSeg) (interface{}, bool, error) {
+    seg, rest := segs[0], segs[1:]
+    switch c := v.(type) {
+    case *MapStrAny:
+        if seg.isIndex {
+            break
+        }
+        removed := false
+        err := c.update(seg.key, func(old interface{}, exists bool) (interface{}, bool, error) {
+            if !exists {
+                return nil, false, errUnchanged
+            }
+            if len(rest) == 0 {
+                removed = true
+                return nil, false, nil
+            }
+            nv, _, err := removeIn(old, rest)
+            removed = err == nil
+            return nv, true, err
+        })
+        if err != nil {
+            return nil, false, err
+        }
+        if !removed {
+            return nil, false, errUnchanged
+        }
+        return c, true, nil
+    case map[string]interface{}:
+        child, ok := c[seg.key]
+        if seg.isIndex || !ok {
+            break
+        }
+        m := maps.Clone(c)
+        if len(rest) == 0 {
+            delete(m, seg.key)
+            return m, true, nil
+        }
+        nv, _, err := removeIn(child, rest)
+        if err != nil {
+            return nil, false, err
+        }
+        m[seg.key] = nv
+        return m, true, nil
+    case []interface{}:
+        i, ok := sliceIndex(seg, len(c))
+        if !ok {
+            break
+        }
+        if len(rest) == 0 {
+            return slices.Delete(slices.Clone(c), i, i+1), true, nil
+        }
+        nv, _, err := removeIn(c[i], rest)
+        if err != nil {
+            return nil, false, err
+        }
+        s := slices.Clone(c)
+        s[i] = nv
+        return s, true, nil
+    }
+    return nil, false, errUnchanged
+}
@@ -0,0 +1,170 @@
+package smap
+
+import (
+    "reflect"
+    "testing"
+)
+
+func getPathMap() *MapStrAny {
+    m := NewMapStrAny(true)
+    nested := NewMapStrAny(true)
+    nested.Set("level", 1)
+    m.Set("db", map[string]interface{}{
+        "host": "localhost",
+        "replicas": []interface{}{
+            map[string]interface{}{"host": "r0"},
+            map[string]interface{}{"host": "r1"},
+        },
+        "ports": []int{5432, 5433},
+        "tags":  map[string]string{"env": "prod"},
+    })
+    m.Set("a.b", "escaped")
+    m.Set("nested", nested)
+    return m
+}
+
+func TestParsePath(t *testing.T) {
+    tests := []struct {
+        name    string
+        path    string
+        sep     rune
+        want    []pathSeg
+        wantErr bool
+    }{
+        {name: "key", path: "a", sep: '.', want: []pathSeg{{key: "a"}}},
+        {name: "dotted", path: "a.b.c", sep: '.', want: []pathSeg{{key: "a"}, {key: "b"}, {key: "c"}}},
+        {name: "index", path: "a[2].b[0][1]", sep: '.', want: []pathSeg{{key: "a"}, {index: 2, isIndex: true}, {key: "b"}, {index: 0, isIndex: true}, {index: 1, isIndex: true}}},
+        {name: "escape", path: `a\.b.c\[0\]\\`, sep: '.', want: []pathSeg{{key: "a.b"}, {key: `c[0]\`}}},
+        {name: "custom separator", path: "a.b/c", sep: '/', want: []pathSeg{{key: "a.b"}, {key: "c"}}},
+        {name: "empty", path: "", sep: '.', wantErr: true},
+        {name: "empty key", path: "a..b", sep: '.', wantErr: true},
+        {name: "trailing separator", path: "a.", sep: '.', wantErr: true},
+        {name: "leading index", path: "[0]", sep: '.', wantErr: true},
+        {name: "bad index", path: "a[x]", sep: '.', wantErr: true},
+        {name: "unclosed index", path: "a[1", sep: '.', wantErr: true},
+        {name: "trailing escape", path: `a\`, sep: '.', wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := parsePath(tt.path, tt.sep)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("parsePath() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("parsePath() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_GetPath(t *testing.T) {
+    m := getPathMap()
+    tests := []struct {
+        name    string
+        path    string
+        want    interface{}
+        wantOk  bool
+        wantErr bool
+    }{
+        {name: "top", path: "db.host", want: "localhost", wantOk: true},
+        {name: "index", path: "db.replicas[1].host", want: "r1", wantOk: true},
+        {name: "numeric key", path: "db.replicas.0.host", want: "r0", wantOk: true},
+        {name: "typed slice", path: "db.ports[1]", want: 5433, wantOk: true},
+        {name: "typed map", path: "db.tags.env", want: "prod", wantOk: true},
+        {name: "escaped", path: `a\.b`, want: "escaped", wantOk: true},
+        {name: "nested MapStrAny", path: "nested.level", want: 1, wantOk: true},
+        {name: "out of range", path: "db.replicas[5].host", want: nil},
+        {name: "missing", path: "db.user", want: nil},
+        {name: "through scalar", path: "db.host.x", want: nil},
+        {name: "invalid", path: "db..host", want: nil, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, ok, err := m.GetPath(tt.path)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("GetPath() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk || m.HasPath(tt.path) != tt.wantOk {
+                t.Errorf("GetPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_SetPath(t *testing.T) {
+    tests := []struct {
+        name    string
+        path    string
+        value   interface{}
+        wantErr bool
+    }{
+        {name: "top", path: "x", value: 1},
+        {name: "create intermediate", path: "x.y.z", value: 1},
+        {name: "replace", path: "db.host", value: "remote"},
+        {name: "slice element", path: "db.replicas[0].host", value: "r9"},
+        {name: "append", path: "db.replicas[2]", value: "r2"},
+        {name: "create slice", path: "list[0].name", value: "n"},
+        {name: "nested MapStrAny", path: "nested.deep.level", value: 2},
+        {name: "out of range", path: "db.replicas[5]", value: 1, wantErr: true},
+        {name: "through scalar", path: "db.host.x", value: 1, wantErr: true},
+        {name: "typed map", path: "db.tags.env", value: "dev", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := getPathMap()
+            before, _, _ := m.Get("db")
+            if err := m.SetPath(tt.path, tt.value); (err != nil) != tt.wantErr {
+                t.Fatalf("SetPath() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            if got, ok, err := m.GetPath(tt.path); !ok || err != nil || !reflect.DeepEqual(got, tt.value) {
+                t.Errorf("GetPath() after SetPath = %v, %v, %v, want %v", got, ok, err, tt.value)
+            }
+            if host := before.(map[string]interface{})["host"]; host != "localhost" {
+                t.Errorf("SetPath() modified shared nested map, host = %v", host)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_RemovePath(t *testing.T) {
+    tests := []struct {
+        name  string
+        path  string
+        check string
+        want  interface{}
+    }{
+        {name: "top", path: "db", check: "db"},
+        {name: "map key", path: "db.host", check: "db.host"},
+        {name: "slice element", path: "db.replicas[0]", check: "db.replicas[0].host", want: "r1"},
+        {name: "inside slice", path: "db.replicas[1].host", check: "db.replicas[1]", want: map[string]interface{}{}},
+        {name: "nested MapStrAny", path: "nested.level", check: "nested.level"},
+        {name: "missing", path: "db.none.x", check: "db.host", want: "localhost"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := getPathMap()
+            if err := m.RemovePath(tt.path); err != nil {
+                t.Fatalf("RemovePath() error = %v", err)
+            }
+            if got, _, _ := m.GetPath(tt.check); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("GetPath(%q) = %v, want %v", tt.check, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_PathSeparator(t *testing.T) {
+    m := NewMapStrAnyWith(WithPathSeparator('/'))
+    if err := m.SetPath("a.b/c", 1); err != nil {
+        t.Fatalf("SetPath() error = %v", err)
+    }
+    if got, ok, _ := m.GetPath("a.b/c"); !ok || got != 1 {
+        t.Errorf("GetPath() = %v, %v, want 1", got, ok)
+    }
+    if !m.Has("a.b") {
+        t.Errorf("SetPath() did not use custom separator, keys = %v", m.Keys())
+    }
+}
@@ -0,0 +1,92 @@
+package smap
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// parsePointer 解析JSON Pointer(RFC 6901)，如"/a/b~1c/0"，空字符串表示整个文档
+func parsePointer(ptr string) ([]string, error) {
+    if ptr == "" {
+        return []string{}, nil
+    }
+    if ptr[0] != '/' {
+        return nil, fmt.Errorf("smap: invalid json pointer %q: must start with '/'", ptr)
+    }
+    tokens := strings.Split(ptr[1:], "/")
+    for i, token := range tokens {
+        for j := 0; j < len(token); j++ {
+            if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+                return nil, fmt.Errorf("smap: invalid json pointer %q: bad escape in %q", ptr, token)
+            }
+        }
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
+
+// formatPointer 把路径格式化为JSON Pointer，keys的元素为string或int
+func formatPointer(keys []interface{}) string {
+    var b strings.Builder
+    for _, key := range keys {
+        b.WriteByte('/')
+        switch k := key.(type) {
+        case string:
+            b.WriteString(strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1"))
+        case int:
+            b.WriteString(strconv.Itoa(k))
+        }
+    }
+    return b.String()
+}
+
+// pointerIndex 解析JSON Pointer中的数组下标，不允许前导0和负数
+func pointerIndex(token string) (int, bool) {
+    if token == "" || (len(token) > 1 && token[0] == '0') {
+        return 0, false
+    }
+    for _, c := range token {
+        if c < '0' || c > '9' {
+            return 0, false
+        }
+    }
+    i, err := strconv.Atoi(token)
+    return i, err == nil
+}
+
+// Pointer 按JSON Pointer(RFC 6901)获取value，如Pointer("/a/b~1c/0")
+// 空字符串表示整个map，此时返回所有数据的副本
+func (a *MapStrAny) Pointer(ptr string) (val interface{}, ok bool, err error) {
+    tokens, err := parsePointer(ptr)
+    if err != nil {
+        return nil, false, err
+    }
+    if len(tokens) == 0 {
+        return a.All(), true, nil
+    }
+    val, ok, err = a.Get(tokens[0])
+    for _, token := range tokens[1:] {
+        if !ok || err != nil {
+            return nil, false, err
+        }
+        val, ok = pointerChild(val, token)
+    }
+    if !ok {
+        return nil, false, nil
+    }
+
+    return val, true, err
+}
+
+// pointerChild 获取容器v中JSON Pointer的一段对应的value
+func pointerChild(v interface{}, token string) (interface{}, bool) {
+    if isList(v) {
+        i, ok := pointerIndex(token)
+        if !ok {
+            return nil, false
+        }
+        return getIn(v, pathSeg{index: i, isIndex: true})
+    }
+    return getIn(v, pathSeg{key: token})
+}
@@ -0,0 +1,80 @@
+package smap
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParsePointer(t *testing.T) {
+    tests := []struct {
+        name    string
+        ptr     string
+        want    []string
+        wantErr bool
+    }{
+        {name: "root", ptr: "", want: []string{}},
+        {name: "empty key", ptr: "/", want: []string{""}},
+        {name: "escape", ptr: "/a/b~1c/m~0n/~01", want: []string{"a", "b/c", "m~n", "~1"}},
+        {name: "no slash", ptr: "a", wantErr: true},
+        {name: "bad escape", ptr: "/a~2", wantErr: true},
+        {name: "trailing tilde", ptr: "/a~", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := parsePointer(tt.ptr)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("parsePointer() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("parsePointer() = %q, want %q", got, tt.want)
+            }
+            if !tt.wantErr && len(got) > 0 {
+                keys := make([]interface{}, len(got))
+                for i, k := range got {
+                    keys[i] = k
+                }
+                if back, _ := parsePointer(formatPointer(keys)); !reflect.DeepEqual(back, got) {
+                    t.Errorf("formatPointer() round trip = %q, want %q", back, got)
+                }
+            }
+        })
+    }
+}
+
+func TestMapStrAny_Pointer(t *testing.T) {
+    m := getMapStrAny(map[string]interface{}{
+        "a":   map[string]interface{}{"b/c": []interface{}{"x", "y"}},
+        "m~n": 8,
+        "":    0,
+    }, true)
+    tests := []struct {
+        name    string
+        ptr     string
+        want    interface{}
+        wantOk  bool
+        wantErr bool
+    }{
+        {name: "escaped slash", ptr: "/a/b~1c/1", want: "y", wantOk: true},
+        {name: "escaped tilde", ptr: "/m~0n", want: 8, wantOk: true},
+        {name: "empty key", ptr: "/", want: 0, wantOk: true},
+        {name: "leading zero", ptr: "/a/b~1c/01"},
+        {name: "dash", ptr: "/a/b~1c/-"},
+        {name: "out of range", ptr: "/a/b~1c/2"},
+        {name: "missing", ptr: "/x/y"},
+        {name: "invalid", ptr: "a", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, ok, err := m.Pointer(tt.ptr)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("Pointer() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk {
+                t.Errorf("Pointer() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+            }
+        })
+    }
+    if all, ok, _ := m.Pointer(""); !ok || len(all.(map[string]interface{})) != 3 {
+        t.Errorf("Pointer(\"\") = %v, want whole map", all)
+    }
+}
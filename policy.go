@@ -0,0 +1,87 @@
+package smap
+
+import (
+    "errors"
+)
+
+// ErrRejected 写入被淘汰策略拒绝，例如数据成本超过了总预算
+var ErrRejected = errors.New("smap: entry rejected by eviction policy")
+
+// EvictionPolicy 淘汰策略，决定超出容量或成本预算时淘汰哪个key
+// 每个分片持有独立的策略实例，所有方法都在持有分片写锁时调用，无需自行加锁
+type EvictionPolicy[K comparable] interface {
+    // Add 记录写入的key，key已存在时表示更新，cost为数据的成本
+    Add(key K, cost int64)
+    // Access 记录key被读取
+    Access(key K)
+    // Remove 移除key，key被删除或过期时调用，对不存在的key应忽略
+    Remove(key K)
+    // Evict 选出下一个被淘汰的key并将其从策略中移除
+    Evict() (key K, ok bool)
+}
+
+// Admitter 淘汰策略可选实现的接口，在写入新key前判断是否允许写入
+type Admitter[K comparable] interface {
+    Admit(key K, cost int64) bool
+}
+
+// admit 判断是否允许写入，需在持有分片写锁时调用
+func (s *shard[K, V]) admit(key K, cost int64, exists bool) bool {
+    if s.maxCost > 0 && cost > s.maxCost {
+        return false
+    }
+    if exists {
+        return true
+    }
+    if adm, ok := s.policy.(Admitter[K]); ok {
+        return adm.Admit(key, cost)
+    }
+    return true
+}
+
+// overflow 检查分片是否超出容量或成本预算，返回对应的淘汰原因
+func (s *shard[K, V]) overflow() (EvictReason, bool) {
+    if s.capacity > 0 && s.current().size > s.capacity {
+        return EvictCapacity, true
+    }
+    if s.maxCost > 0 && s.cost > s.maxCost {
+        return EvictCost, true
+    }
+    return 0, false
+}
+
+// initPolicy 按配置为每个分片创建淘汰策略
+// 设置了容量或成本预算但未指定淘汰策略时使用LRU
+func (a *Map[K, V]) initPolicy(o *options) {
+    if o.capacity <= 0 && o.maxCost <= 0 && o.policy == nil {
+        return
+    }
+    factory := NewLRUPolicy[K]
+    if o.policy != nil {
+        factory = assertOption[func() EvictionPolicy[K]]("eviction policy", o.policy)
+    }
+    n := len(a.shards)
+    for _, s := range a.shards {
+        if o.capacity > 0 {
+            s.capacity = (o.capacity + n - 1) / n
+        }
+        if o.maxCost > 0 {
+            s.maxCost = (o.maxCost + int64(n) - 1) / int64(n)
+        }
+        s.policy = factory()
+    }
+}
+
+// SetWithCost 设置k/v并指定数据的成本，使用默认过期时间
+// 配置了WithMaxCost时所有数据的成本之和不超过预算，成本超过预算的数据会被拒绝并返回ErrRejected
+// 未指定成本的写操作成本均为1
+func (a *Map[K, V]) SetWithCost(key K, value V, cost int64) (err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    e := a.newEntry(value)
+    e.cost = cost
+
+    return a.storeLocked(s, key, e)
+}
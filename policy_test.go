@@ -0,0 +1,208 @@
+package smap
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+)
+
+// evictAll 依次淘汰策略中的所有key
+func evictAll[K comparable](p EvictionPolicy[K]) []K {
+    keys := make([]K, 0)
+    for {
+        key, ok := p.Evict()
+        if !ok {
+            return keys
+        }
+        keys = append(keys, key)
+    }
+}
+
+func TestEvictionPolicy_order(t *testing.T) {
+    tests := []struct {
+        name string
+        p    EvictionPolicy[string]
+        ops  func(p EvictionPolicy[string])
+        want []string
+    }{
+        {name: "lru", p: NewLRUPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("a", 1)
+            p.Add("b", 1)
+            p.Add("c", 1)
+            p.Access("a")
+            p.Remove("b")
+        }, want: []string{"c", "a"}},
+        {name: "lfu", p: NewLFUPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("a", 1)
+            p.Add("b", 1)
+            p.Add("c", 1)
+            p.Access("a")
+            p.Access("a")
+            p.Access("c")
+        }, want: []string{"b", "c", "a"}},
+        {name: "lfu remove", p: NewLFUPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("a", 1)
+            p.Add("b", 1)
+            p.Remove("a")
+            p.Remove("x")
+        }, want: []string{"b"}},
+        {name: "2q", p: New2QPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("a", 1)
+            p.Add("b", 1)
+            p.Add("c", 1)
+            p.Access("a")
+        }, want: []string{"b", "a", "c"}},
+        {name: "cost", p: NewCostPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("small", 1)
+            p.Add("big", 100)
+            p.Add("medium", 10)
+        }, want: []string{"big", "medium", "small"}},
+        {name: "cost frequency", p: NewCostPolicy[string](), ops: func(p EvictionPolicy[string]) {
+            p.Add("a", 10)
+            p.Add("b", 10)
+            p.Access("a")
+        }, want: []string{"b", "a"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.ops(tt.p)
+            if got := evictAll(tt.p); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Evict() order = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNew2QPolicy_ghost(t *testing.T) {
+    p := New2QPolicy[int]()
+    for i := 0; i < 8; i++ {
+        p.Add(i, 1)
+    }
+    key, _ := p.Evict()
+    if key != 0 {
+        t.Fatalf("Evict() = %v, want %v", key, 0)
+    }
+    // 被淘汰后再次写入的key进入hot队列，不会先于in队列中的key被淘汰
+    p.Add(0, 1)
+    for i := 1; i < 6; i++ {
+        if key, _ := p.Evict(); key != i {
+            t.Fatalf("Evict() = %v, want %v", key, i)
+        }
+    }
+    if got := len(evictAll(p)); got != 3 {
+        t.Errorf("remaining keys = %v, want %v", got, 3)
+    }
+}
+
+func TestMap_policy_scan(t *testing.T) {
+    tests := []struct {
+        name    string
+        factory func() EvictionPolicy[int]
+        wantHot bool
+    }{
+        {name: "lru", factory: NewLRUPolicy[int], wantHot: false},
+        {name: "lfu", factory: NewLFUPolicy[int], wantHot: true},
+        {name: "2q", factory: New2QPolicy[int], wantHot: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapWith[int, int](WithCapacity(10), WithEvictionPolicy(tt.factory))
+            for i := 0; i < 5; i++ {
+                m.Set(i, i)
+            }
+            for r := 0; r < 3; r++ {
+                for i := 0; i < 5; i++ {
+                    m.Get(i)
+                }
+            }
+            // 一次性扫描大量冷数据
+            for i := 100; i < 200; i++ {
+                m.Set(i, i)
+            }
+            hot := true
+            for i := 0; i < 5; i++ {
+                if !m.Has(i) {
+                    hot = false
+                }
+            }
+            if hot != tt.wantHot {
+                t.Errorf("hot keys kept = %v, want %v", hot, tt.wantHot)
+            }
+            if m.Size() != 10 {
+                t.Errorf("Size() = %v, want %v", m.Size(), 10)
+            }
+        })
+    }
+}
+
+func TestMap_SetWithCost(t *testing.T) {
+    evicted := make(map[string]EvictReason)
+    m := NewMapWith[string, string](WithMaxCost(10), WithEvictionPolicy(NewCostPolicy[string]),
+        WithEvictCallback(func(key string, value string, reason EvictReason) {
+            evicted[key] = reason
+        }))
+    tests := []struct {
+        name    string
+        key     string
+        cost    int64
+        wantErr error
+    }{
+        {name: "fits", key: "a", cost: 4},
+        {name: "fits again", key: "b", cost: 4},
+        {name: "over budget", key: "c", cost: 4},
+        {name: "too large", key: "d", cost: 11, wantErr: ErrRejected},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := m.SetWithCost(tt.key, tt.key, tt.cost); !errors.Is(err, tt.wantErr) {
+                t.Errorf("SetWithCost() error = %v, want %v", err, tt.wantErr)
+            }
+        })
+    }
+    if m.shards[0].cost > 10 {
+        t.Errorf("total cost = %v, want <= 10", m.shards[0].cost)
+    }
+    if m.Size() != 2 || m.Has("d") || !m.Has("c") {
+        t.Errorf("Keys() = %v", m.Keys())
+    }
+    if len(evicted) != 1 {
+        t.Errorf("evicted = %v, want one key", evicted)
+    }
+    for _, reason := range evicted {
+        if reason != EvictCost {
+            t.Errorf("evict reason = %v, want %v", reason, EvictCost)
+        }
+    }
+    m.Remove("c")
+    if m.shards[0].cost != 4 {
+        t.Errorf("total cost after Remove = %v, want 4", m.shards[0].cost)
+    }
+}
+
+func TestWithEvictionPolicy_mismatch(t *testing.T) {
+    defer func() {
+        if r := recover(); r == nil {
+            t.Errorf("NewMapWith() did not panic on mismatched policy")
+        }
+    }()
+    NewMapWith[string, int](WithEvictionPolicy(NewLRUPolicy[int]))
+}
+
+func TestMap_Stats(t *testing.T) {
+    m := NewMapWith[string, int](WithCapacity(1))
+    m.Set("a", 1)
+    m.Get("a")
+    m.Get("b")
+    m.Set("b", 2)
+    want := Stats{Hits: 1, Misses: 1, Evictions: 1}
+    if got := m.Stats(); got != want {
+        t.Errorf("Stats() = %+v, want %+v", got, want)
+    }
+    if got := m.Stats().HitRatio(); got != 0.5 {
+        t.Errorf("HitRatio() = %v, want %v", got, 0.5)
+    }
+    m.ResetStats()
+    if got := m.Stats(); got != (Stats{}) || got.HitRatio() != 0 {
+        t.Errorf("Stats() after reset = %+v", got)
+    }
+}
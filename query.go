@@ -0,0 +1,486 @@
+package smap
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Match 查询匹配到的value及其路径
+type Match struct {
+    // Path 规范化的JSONPath，如$['db']['replicas'][0]
+    Path string
+    // Pointer 对应的JSON Pointer，如/db/replicas/0
+    Pointer string
+    Value   interface{}
+}
+
+// queryNode 查询过程中的节点，keys为从根节点到当前节点的路径，元素为string或int
+type queryNode struct {
+    value interface{}
+    keys  []interface{}
+}
+
+// child 创建子节点
+func (n queryNode) child(key interface{}, value interface{}) queryNode {
+    keys := make([]interface{}, len(n.keys)+1)
+    copy(keys, n.keys)
+    keys[len(n.keys)] = key
+    return queryNode{value: value, keys: keys}
+}
+
+// match 转换为查询结果
+func (n queryNode) match() Match {
+    var b strings.Builder
+    b.WriteByte('$')
+    for _, key := range n.keys {
+        switch k := key.(type) {
+        case string:
+            b.WriteString("['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(k) + "']")
+        case int:
+            b.WriteString("[" + strconv.Itoa(k) + "]")
+        }
+    }
+    return Match{Path: b.String(), Pointer: formatPointer(n.keys), Value: n.value}
+}
+
+// isList 检查v是否为切片或数组
+func isList(v interface{}) bool {
+    if _, ok := v.([]interface{}); ok {
+        return true
+    }
+    if v == nil {
+        return false
+    }
+    kind := reflect.ValueOf(v).Kind()
+    return kind == reflect.Slice || kind == reflect.Array
+}
+
+// listLen 获取切片或数组的长度
+func listLen(v interface{}) int {
+    if s, ok := v.([]interface{}); ok {
+        return len(s)
+    }
+    return reflect.ValueOf(v).Len()
+}
+
+// children 按key排序或下标顺序获取容器v的所有子节点，v不是容器时返回nil
+func children(n queryNode) []queryNode {
+    if isList(n.value) {
+        nodes := make([]queryNode, 0, listLen(n.value))
+        for i := 0; i < listLen(n.value); i++ {
+            v, _ := getIn(n.value, pathSeg{index: i, isIndex: true})
+            nodes = append(nodes, n.child(i, v))
+        }
+        return nodes
+    }
+    keys := objectKeys(n.value)
+    nodes := make([]queryNode, 0, len(keys))
+    for _, key := range keys {
+        if v, ok := getIn(n.value, pathSeg{key: key}); ok {
+            nodes = append(nodes, n.child(key, v))
+        }
+    }
+    return nodes
+}
+
+// objectKeys 获取对象的所有key并排序，v不是对象时返回nil
+func objectKeys(v interface{}) []string {
+    var keys []string
+    switch c := v.(type) {
+    case *MapStrAny:
+        keys = c.Keys()
+    case map[string]interface{}:
+        keys = make([]string, 0, len(c))
+        for k := range c {
+            keys = append(keys, k)
+        }
+    case nil:
+        return nil
+    default:
+        rv := reflect.ValueOf(v)
+        if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+            return nil
+        }
+        for _, k := range rv.MapKeys() {
+            keys = append(keys, k.String())
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Query 使用JSONPath表达式查询数据，返回所有匹配的value及其路径
+// 支持$、.name、['name']、[n]、[start:end:step]、[a,b]、*、..递归查询及[?(@.price < 10 && @.tag)]过滤
+// 对象的子节点按key排序后返回
+func (a *MapStrAny) Query(expr string) ([]Match, error) {
+    steps, err := parseJSONPath(expr)
+    if err != nil {
+        return nil, err
+    }
+    root := queryNode{value: a.All(), keys: []interface{}{}}
+    nodes := evalSteps(root, root, steps)
+    matches := make([]Match, 0, len(nodes))
+    for _, n := range nodes {
+        matches = append(matches, n.match())
+    }
+    return matches, nil
+}
+
+// queryStep JSONPath的一步，descendant表示..递归查询
+type queryStep struct {
+    descendant bool
+    selectors  []selector
+}
+
+// selector 选择器，按kind区分
+type selector struct {
+    kind  selectorKind
+    name  string
+    index int
+    // slice的start/end/step，nil表示省略
+    start, end, step *int
+    filter           filterExpr
+}
+
+// selectorKind 选择器类型
+type selectorKind int
+
+const (
+    selectName selectorKind = iota
+    selectWildcard
+    selectIndex
+    selectSlice
+    selectFilter
+)
+
+// evalSteps 从node开始依次执行steps，root为$对应的根节点
+func evalSteps(root, node queryNode, steps []queryStep) []queryNode {
+    nodes := []queryNode{node}
+    for _, step := range steps {
+        next := make([]queryNode, 0)
+        for _, n := range nodes {
+            targets := []queryNode{n}
+            if step.descendant {
+                targets = descendants(n, targets[:0])
+            }
+            for _, t := range targets {
+                for _, sel := range step.selectors {
+                    next = append(next, sel.apply(root, t)...)
+                }
+            }
+        }
+        nodes = next
+    }
+    return nodes
+}
+
+// descendants 按先序遍历获取节点自身及所有后代节点
+func descendants(n queryNode, nodes []queryNode) []queryNode {
+    nodes = append(nodes, n)
+    for _, c := range children(n) {
+        nodes = descendants(c, nodes)
+    }
+    return nodes
+}
+
+// apply 对节点执行选择器
+func (sel selector) apply(root, n queryNode) []queryNode {
+    switch sel.kind {
+    case selectName:
+        if isList(n.value) {
+            return nil
+        }
+        if v, ok := getIn(n.value, pathSeg{key: sel.name}); ok {
+            return []queryNode{n.child(sel.name, v)}
+        }
+    case selectWildcard:
+        return children(n)
+    case selectIndex:
+        if !isList(n.value) {
+            return nil
+        }
+        i := sel.index
+        if i < 0 {
+            i += listLen(n.value)
+        }
+        if v, ok := getIn(n.value, pathSeg{index: i, isIndex: true}); ok {
+            return []queryNode{n.child(i, v)}
+        }
+    case selectSlice:
+        if !isList(n.value) {
+            return nil
+        }
+        nodes := make([]queryNode, 0)
+        for _, i := range sliceIndexes(listLen(n.value), sel.start, sel.end, sel.step) {
+            v, _ := getIn(n.value, pathSeg{index: i, isIndex: true})
+            nodes = append(nodes, n.child(i, v))
+        }
+        return nodes
+    case selectFilter:
+        nodes := make([]queryNode, 0)
+        for _, c := range children(n) {
+            if truthy(sel.filter.eval(root, c)) {
+                nodes = append(nodes, c)
+            }
+        }
+        return nodes
+    }
+    return nil
+}
+
+// sliceIndexes 按RFC 9535的切片语义计算下标
+func sliceIndexes(n int, start, end, step *int) []int {
+    s := 1
+    if step != nil {
+        s = *step
+    }
+    if s == 0 {
+        return nil
+    }
+    norm := func(i int) int {
+        if i < 0 {
+            return i + n
+        }
+        return i
+    }
+    indexes := make([]int, 0)
+    if s > 0 {
+        lo, hi := 0, n
+        if start != nil {
+            lo = min(max(norm(*start), 0), n)
+        }
+        if end != nil {
+            hi = min(max(norm(*end), 0), n)
+        }
+        for i := lo; i < hi; i += s {
+            indexes = append(indexes, i)
+        }
+        return indexes
+    }
+    hi, lo := n-1, -1
+    if start != nil {
+        hi = min(max(norm(*start), -1), n-1)
+    }
+    if end != nil {
+        lo = min(max(norm(*end), -1), n-1)
+    }
+    for i := hi; i > lo; i += s {
+        indexes = append(indexes, i)
+    }
+    return indexes
+}
+
+// pathParser JSONPath解析器
+type pathParser struct {
+    expr string
+    pos  int
+}
+
+// errorf 创建解析错误
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+    return fmt.Errorf("smap: invalid jsonpath %q at %d: %s", p.expr, p.pos, fmt.Sprintf(format, args...))
+}
+
+// peek 查看当前字符，已到末尾时返回0
+func (p *pathParser) peek() byte {
+    if p.pos < len(p.expr) {
+        return p.expr[p.pos]
+    }
+    return 0
+}
+
+// skipSpace 跳过空白字符
+func (p *pathParser) skipSpace() {
+    for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+        p.pos++
+    }
+}
+
+// parseJSONPath 解析以$开头的JSONPath表达式
+func parseJSONPath(expr string) ([]queryStep, error) {
+    p := &pathParser{expr: strings.TrimSpace(expr)}
+    if p.peek() != '$' {
+        return nil, p.errorf("must start with '$'")
+    }
+    p.pos++
+    steps, err := p.parseSteps()
+    if err != nil {
+        return nil, err
+    }
+    if p.pos != len(p.expr) {
+        return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+    }
+    return steps, nil
+}
+
+// parseSteps 解析路径中的所有步骤，遇到不属于路径的字符时停止
+func (p *pathParser) parseSteps() ([]queryStep, error) {
+    steps := make([]queryStep, 0)
+    for {
+        switch p.peek() {
+        case '.':
+            p.pos++
+            step := queryStep{}
+            if p.peek() == '.' {
+                p.pos++
+                step.descendant = true
+                if p.peek() == '[' {
+                    sels, err := p.parseBracket()
+                    if err != nil {
+                        return nil, err
+                    }
+                    step.selectors = sels
+                    steps = append(steps, step)
+                    continue
+                }
+            }
+            sel, err := p.parseDotted()
+            if err != nil {
+                return nil, err
+            }
+            step.selectors = []selector{sel}
+            steps = append(steps, step)
+        case '[':
+            sels, err := p.parseBracket()
+            if err != nil {
+                return nil, err
+            }
+            steps = append(steps, queryStep{selectors: sels})
+        default:
+            return steps, nil
+        }
+    }
+}
+
+// parseDotted 解析.后的名称或*
+func (p *pathParser) parseDotted() (selector, error) {
+    if p.peek() == '*' {
+        p.pos++
+        return selector{kind: selectWildcard}, nil
+    }
+    start := p.pos
+    for p.pos < len(p.expr) && isNameChar(p.expr[p.pos]) {
+        p.pos++
+    }
+    if start == p.pos {
+        return selector{}, p.errorf("expected name")
+    }
+    return selector{kind: selectName, name: p.expr[start:p.pos]}, nil
+}
+
+// isNameChar 检查c是否可以出现在.name中
+func isNameChar(c byte) bool {
+    return c == '_' || c == '-' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// parseBracket 解析[]中以逗号分隔的选择器
+func (p *pathParser) parseBracket() ([]selector, error) {
+    p.pos++
+    sels := make([]selector, 0)
+    for {
+        p.skipSpace()
+        sel, err := p.parseSelector()
+        if err != nil {
+            return nil, err
+        }
+        sels = append(sels, sel)
+        p.skipSpace()
+        switch p.peek() {
+        case ',':
+            p.pos++
+        case ']':
+            p.pos++
+            return sels, nil
+        default:
+            return nil, p.errorf("expected ',' or ']'")
+        }
+    }
+}
+
+// parseSelector 解析[]中的单个选择器
+func (p *pathParser) parseSelector() (selector, error) {
+    switch c := p.peek(); {
+    case c == '*':
+        p.pos++
+        return selector{kind: selectWildcard}, nil
+    case c == '\'' || c == '"':
+        name, err := p.parseString()
+        return selector{kind: selectName, name: name}, err
+    case c == '?':
+        p.pos++
+        expr, err := p.parseOr()
+        return selector{kind: selectFilter, filter: expr}, err
+    }
+    return p.parseIndexOrSlice()
+}
+
+// parseIndexOrSlice 解析下标n或切片start:end:step
+func (p *pathParser) parseIndexOrSlice() (selector, error) {
+    parts := make([]*int, 0, 3)
+    for {
+        p.skipSpace()
+        start := p.pos
+        if p.peek() == '-' {
+            p.pos++
+        }
+        for p.pos < len(p.expr) && '0' <= p.expr[p.pos] && p.expr[p.pos] <= '9' {
+            p.pos++
+        }
+        var part *int
+        if p.pos > start {
+            n, err := strconv.Atoi(p.expr[start:p.pos])
+            if err != nil {
+                return selector{}, p.errorf("bad number %q", p.expr[start:p.pos])
+            }
+            part = &n
+        }
+        parts = append(parts, part)
+        p.skipSpace()
+        if p.peek() != ':' || len(parts) == 3 {
+            break
+        }
+        p.pos++
+    }
+    if len(parts) == 1 {
+        if parts[0] == nil {
+            return selector{}, p.errorf("expected selector")
+        }
+        return selector{kind: selectIndex, index: *parts[0]}, nil
+    }
+    for len(parts) < 3 {
+        parts = append(parts, nil)
+    }
+    return selector{kind: selectSlice, start: parts[0], end: parts[1], step: parts[2]}, nil
+}
+
+// parseString 解析单引号或双引号字符串，支持\转义
+func (p *pathParser) parseString() (string, error) {
+    quote := p.expr[p.pos]
+    p.pos++
+    var b strings.Builder
+    for p.pos < len(p.expr) {
+        c := p.expr[p.pos]
+        p.pos++
+        switch {
+        case c == quote:
+            return b.String(), nil
+        case c == '\\' && p.pos < len(p.expr):
+            e := p.expr[p.pos]
+            p.pos++
+            switch e {
+            case 'n':
+                b.WriteByte('\n')
+            case 't':
+                b.WriteByte('\t')
+            default:
+                b.WriteByte(e)
+            }
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return "", p.errorf("unterminated string")
+}
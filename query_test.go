@@ -0,0 +1,88 @@
+package smap
+
+import (
+    "reflect"
+    "testing"
+)
+
+func getQueryMap() *MapStrAny {
+    inner := NewMapStrAny(true)
+    inner.Set("color", "red")
+    inner.Set("price", 19.95)
+    return getMapStrAny(map[string]interface{}{
+        "store": map[string]interface{}{
+            "book": []interface{}{
+                map[string]interface{}{"category": "reference", "author": "Rees", "price": 8.95},
+                map[string]interface{}{"category": "fiction", "author": "Waugh", "price": 12.99},
+                map[string]interface{}{"category": "fiction", "author": "Melville", "price": 8.99, "isbn": "0-553"},
+                map[string]interface{}{"category": "fiction", "author": "Tolkien", "price": 22.99, "isbn": "0-395"},
+            },
+            "bicycle": inner,
+        },
+        "it's": 1,
+    }, true)
+}
+
+func TestMapStrAny_Query(t *testing.T) {
+    m := getQueryMap()
+    tests := []struct {
+        name    string
+        expr    string
+        want    []string
+        wantErr bool
+    }{
+        {name: "root", expr: "$", want: []string{"$"}},
+        {name: "member", expr: "$.store.bicycle.color", want: []string{"$['store']['bicycle']['color']"}},
+        {name: "bracket", expr: "$['store']['book'][0]['author']", want: []string{"$['store']['book'][0]['author']"}},
+        {name: "quoted escape", expr: `$['it\'s']`, want: []string{`$['it\'s']`}},
+        {name: "negative index", expr: "$.store.book[-1].author", want: []string{"$['store']['book'][3]['author']"}},
+        {name: "negative index out of range", expr: "$.store.book[-10]", want: []string{}},
+        {name: "index out of range", expr: "$.store.book[10]", want: []string{}},
+        {name: "wildcard", expr: "$.store.book[*].author", want: []string{
+            "$['store']['book'][0]['author']", "$['store']['book'][1]['author']",
+            "$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+        {name: "union", expr: "$.store.book[0,2].price", want: []string{"$['store']['book'][0]['price']", "$['store']['book'][2]['price']"}},
+        {name: "slice", expr: "$.store.book[1:3]", want: []string{"$['store']['book'][1]", "$['store']['book'][2]"}},
+        {name: "slice step", expr: "$.store.book[::-2]", want: []string{"$['store']['book'][3]", "$['store']['book'][1]"}},
+        {name: "recursive", expr: "$..isbn", want: []string{"$['store']['book'][2]['isbn']", "$['store']['book'][3]['isbn']"}},
+        {name: "recursive nested MapStrAny", expr: "$..color", want: []string{"$['store']['bicycle']['color']"}},
+        {name: "filter exists", expr: "$.store.book[?(@.isbn)].author", want: []string{"$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+        {name: "filter compare", expr: "$.store.book[?@.price < 10].author", want: []string{"$['store']['book'][0]['author']", "$['store']['book'][2]['author']"}},
+        {name: "filter and or", expr: "$..book[?(@.category == 'fiction' && (@.price > 20 || !@.isbn))].author", want: []string{"$['store']['book'][1]['author']", "$['store']['book'][3]['author']"}},
+        {name: "filter root", expr: "$.store.book[?(@.price > $.store.bicycle.price)].author", want: []string{"$['store']['book'][3]['author']"}},
+        {name: "no match", expr: "$.store.car", want: []string{}},
+        {name: "no root", expr: "store", wantErr: true},
+        {name: "unclosed", expr: "$.store[0", wantErr: true},
+        {name: "trailing", expr: "$.store)", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := m.Query(tt.expr)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            paths := make([]string, 0, len(got))
+            for _, match := range got {
+                paths = append(paths, match.Path)
+            }
+            if !reflect.DeepEqual(paths, tt.want) {
+                t.Errorf("Query() = %v, want %v", paths, tt.want)
+            }
+        })
+    }
+}
+
+func TestMapStrAny_QueryMatch(t *testing.T) {
+    m := getQueryMap()
+    got, err := m.Query("$.store.book[?(@.author == 'Tolkien')].isbn")
+    want := []Match{{Path: "$['store']['book'][3]['isbn']", Pointer: "/store/book/3/isbn", Value: "0-395"}}
+    if err != nil || !reflect.DeepEqual(got, want) {
+        t.Fatalf("Query() = %v, %v, want %v", got, err, want)
+    }
+    if v, ok, _ := m.Pointer(got[0].Pointer); !ok || v != "0-395" {
+        t.Errorf("Pointer(%q) = %v, want 0-395", got[0].Pointer, v)
+    }
+}
@@ -0,0 +1,68 @@
+package smap
+
+// entryPair 用于快照遍历的k/v对
+type entryPair[K comparable, V any] struct {
+    key   K
+    value V
+}
+
+// Range 遍历所有k/v，fn返回false时停止遍历
+// 遍历时逐个分片持有读锁，fn内不能调用当前map的写方法
+func (a *Map[K, V]) Range(fn func(key K, value V) bool) {
+    now := a.now()
+    for _, s := range a.shards {
+        if !a.rangeShard(s, now, fn) {
+            return
+        }
+    }
+}
+
+// rangeShard 在读锁下遍历单个分片
+func (a *Map[K, V]) rangeShard(s *shard[K, V], now int64, fn func(key K, value V) bool) bool {
+    s.rlock()
+    defer s.runlock()
+    for k, e := range s.view().all() {
+        if e.expired(now) {
+            continue
+        }
+        if !fn(k, e.value) {
+            return false
+        }
+    }
+    return true
+}
+
+// SnapshotRange 遍历某一时刻的数据副本，fn返回false时停止遍历
+// 遍历时不持有锁，fn内可以调用当前map的任意方法
+func (a *Map[K, V]) SnapshotRange(fn func(key K, value V) bool) {
+    now := a.now()
+    a.rlockAll()
+    pairs := make([]entryPair[K, V], 0)
+    for _, s := range a.shards {
+        for k, e := range s.view().all() {
+            if !e.expired(now) {
+                pairs = append(pairs, entryPair[K, V]{key: k, value: e.value})
+            }
+        }
+    }
+    a.runlockAll()
+    for _, p := range pairs {
+        if !fn(p.key, p.value) {
+            return
+        }
+    }
+}
+
+// rlockAll 按顺序对所有分片加读锁
+func (a *Map[K, V]) rlockAll() {
+    for _, s := range a.shards {
+        s.rlock()
+    }
+}
+
+// runlockAll 释放所有分片的读锁
+func (a *Map[K, V]) runlockAll() {
+    for i := len(a.shards) - 1; i >= 0; i-- {
+        a.shards[i].runlock()
+    }
+}
@@ -0,0 +1,84 @@
+package smap
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestMap_Range(t *testing.T) {
+    data := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+    tests := []struct {
+        name      string
+        a         *MapStrAny
+        stopAfter int
+        wantCount int
+    }{
+        {name: "unsafe all", a: getMapStrAny(data), stopAfter: -1, wantCount: 3},
+        {name: "unsafe stop", a: getMapStrAny(data), stopAfter: 1, wantCount: 1},
+        {name: "unsafe empty", a: getMapStrAny(nil), stopAfter: -1, wantCount: 0},
+        {name: "safe all", a: getMapStrAny(data, true), stopAfter: -1, wantCount: 3},
+        {name: "safe stop", a: getMapStrAny(data, true), stopAfter: 2, wantCount: 2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := make(map[string]interface{})
+            tt.a.Range(func(key string, value interface{}) bool {
+                got[key] = value
+                return len(got) != tt.stopAfter
+            })
+            if len(got) != tt.wantCount {
+                t.Errorf("Range() visited %v, want %v", len(got), tt.wantCount)
+            }
+            for k, v := range got {
+                if data[k] != v {
+                    t.Errorf("Range() got %v=%v, want %v", k, v, data[k])
+                }
+            }
+        })
+    }
+}
+
+func TestMap_Range_sharded(t *testing.T) {
+    m := NewMapWith[int, int](WithShards(8))
+    for i := 0; i < 100; i++ {
+        m.Set(i, i)
+    }
+    count := 0
+    m.Range(func(key int, value int) bool {
+        count++
+        return count < 50
+    })
+    if count != 50 {
+        t.Errorf("Range() visited %v, want %v", count, 50)
+    }
+}
+
+func TestMap_SnapshotRange(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *MapAny
+    }{
+        {name: "unsafe", a: getMapAny(map[interface{}]interface{}{1: 1, 2: 2})},
+        {name: "safe", a: getMapAny(map[interface{}]interface{}{1: 1, 2: 2}, true)},
+        {name: "sharded", a: getMapAnySharded(map[interface{}]interface{}{1: 1, 2: 2})},
+        {name: "cow", a: func() *MapAny {
+            m := NewMapAnyWith(WithCopyOnWrite())
+            m.Set(1, 1)
+            m.Set(2, 2)
+            return m
+        }()},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.a.SnapshotRange(func(key interface{}, value interface{}) bool {
+                tt.a.Remove(key)
+                tt.a.Set(key.(int)*10, value)
+                return true
+            })
+            want := map[interface{}]interface{}{10: 1, 20: 2}
+            if got := tt.a.All(); !reflect.DeepEqual(got, want) {
+                t.Errorf("SnapshotRange() result = %v, want %v", got, want)
+            }
+        })
+    }
+}
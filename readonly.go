@@ -0,0 +1,37 @@
+package smap
+
+// ReadOnlyMap Map的只读视图，与原Map共享数据且不提供任何修改方法
+// 读操作与原Map一样加锁，适合把map交给外部代码只读使用
+type ReadOnlyMap[K comparable, V any] struct {
+    m *Map[K, V]
+}
+
+// ReadOnly 获取只读视图，不复制数据
+func (a *Map[K, V]) ReadOnly() *ReadOnlyMap[K, V] {
+    return &ReadOnlyMap[K, V]{m: a}
+}
+
+// Get 获取value
+func (r *ReadOnlyMap[K, V]) Get(key K) (val V, ok bool, err error) {
+    return r.m.Get(key)
+}
+
+// Has 检查key是否存在
+func (r *ReadOnlyMap[K, V]) Has(key K) bool {
+    return r.m.Has(key)
+}
+
+// Keys 获取所有key
+func (r *ReadOnlyMap[K, V]) Keys() []K {
+    return r.m.Keys()
+}
+
+// Size 获取数据长度
+func (r *ReadOnlyMap[K, V]) Size() int {
+    return r.m.Size()
+}
+
+// Range 遍历所有k/v，fn返回false时停止遍历
+func (r *ReadOnlyMap[K, V]) Range(fn func(key K, value V) bool) {
+    r.m.Range(fn)
+}
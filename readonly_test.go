@@ -0,0 +1,63 @@
+package smap
+
+import (
+    "reflect"
+    "sort"
+    "testing"
+)
+
+func TestMap_ReadOnly(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *MapStrAny
+    }{
+        {name: "unsafe", a: getMapStrAny(map[string]interface{}{"a": 1})},
+        {name: "safe", a: getMapStrAny(map[string]interface{}{"a": 1}, true)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := tt.a.ReadOnly()
+            tt.a.Set("b", 2)
+            if v, ok, err := r.Get("b"); v != 2 || !ok || err != nil {
+                t.Errorf("Get() = %v, %v, %v, want shared storage", v, ok, err)
+            }
+            if !r.Has("a") || r.Has("c") {
+                t.Errorf("Has() = %v, %v", r.Has("a"), r.Has("c"))
+            }
+            keys := r.Keys()
+            sort.Strings(keys)
+            if !reflect.DeepEqual(keys, []string{"a", "b"}) || r.Size() != 2 {
+                t.Errorf("Keys() = %v, Size() = %v", keys, r.Size())
+            }
+            count := 0
+            r.Range(func(key string, value interface{}) bool {
+                count++
+                return true
+            })
+            if count != 2 {
+                t.Errorf("Range() visited %v, want %v", count, 2)
+            }
+        })
+    }
+}
+
+func TestMap_All_copy(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *MapAny
+    }{
+        {name: "unsafe", a: getMapAny(map[interface{}]interface{}{"a": 1})},
+        {name: "safe", a: getMapAny(map[interface{}]interface{}{"a": 1}, true)},
+        {name: "sharded", a: getMapAnySharded(map[interface{}]interface{}{"a": 1})},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            all := tt.a.All()
+            all["b"] = 2
+            delete(all, "a")
+            if !tt.a.Has("a") || tt.a.Has("b") {
+                t.Errorf("All() returned internal data")
+            }
+        })
+    }
+}
@@ -0,0 +1,207 @@
+package smap
+
+import (
+    "maps"
+    "sync"
+    "sync/atomic"
+)
+
+// shard 独立加锁的数据分片
+type shard[K comparable, V any] struct {
+    mutex *sync.RWMutex
+    data  map[K]entry[V]
+    // cow 写时复制模式，读操作从published读取不加锁
+    cow       bool
+    published atomic.Pointer[map[K]entry[V]]
+    // staging 为true时修改暂存在staged中，调用publish后才对读操作可见
+    staging bool
+    staged  map[K]entry[V]
+    // shares 与快照共享data的数量，共享期间data不再修改，修改写入overlay，added 为overlay中新增key的数量
+    shares  int
+    overlay map[K]overlayEntry[V]
+    added   int
+    // capacity 分片容量，maxCost 分片成本预算，为0时不限制
+    capacity int
+    maxCost  int64
+    cost     int64
+    policy   EvictionPolicy[K]
+    // evicted 持有锁期间产生的淘汰记录，释放锁后回调
+    evicted []eviction[K, V]
+}
+
+// newShard 创建一个分片
+func newShard[K comparable, V any](safe, cow bool) *shard[K, V] {
+    s := &shard[K, V]{data: make(map[K]entry[V]), cow: cow}
+    if safe {
+        s.mutex = new(sync.RWMutex)
+    }
+    if cow {
+        data := s.data
+        s.published.Store(&data)
+    }
+    return s
+}
+
+// lock 加写锁
+func (s *shard[K, V]) lock() {
+    if s.mutex != nil {
+        s.mutex.Lock()
+    }
+}
+
+// unlock 释放写锁
+func (s *shard[K, V]) unlock() {
+    if s.mutex != nil {
+        s.mutex.Unlock()
+    }
+}
+
+// rlock 加读锁，写时复制模式下读操作不加锁
+func (s *shard[K, V]) rlock() {
+    if s.mutex != nil && !s.cow {
+        s.mutex.RLock()
+    }
+}
+
+// runlock 释放读锁
+func (s *shard[K, V]) runlock() {
+    if s.mutex != nil && !s.cow {
+        s.mutex.RUnlock()
+    }
+}
+
+// view 获取用于读取的数据，需在rlock之后调用
+func (s *shard[K, V]) view() layer[K, V] {
+    if s.cow {
+        data := *s.published.Load()
+        return layer[K, V]{base: data, size: len(data)}
+    }
+    return s.current()
+}
+
+// current 获取最新的数据，需在lock之后调用
+func (s *shard[K, V]) current() layer[K, V] {
+    return layer[K, V]{base: s.data, overlay: s.overlay, size: len(s.data) + s.added}
+}
+
+// writable 获取用于修改的数据，需在lock之后调用
+// 写时复制模式下返回当前数据的副本，修改完成后需调用commit发布
+func (s *shard[K, V]) writable() map[K]entry[V] {
+    if s.cow {
+        if s.staged != nil {
+            return s.staged
+        }
+        return maps.Clone(s.data)
+    }
+    return s.data
+}
+
+// put 写入数据项，需在lock之后调用，data被快照共享时写入overlay
+func (s *shard[K, V]) put(key K, e entry[V]) {
+    if s.shares == 0 {
+        data := s.writable()
+        data[key] = e
+        s.commit(data)
+        return
+    }
+    if _, ok := s.current().get(key); !ok {
+        s.added++
+    }
+    s.overlayed()[key] = overlayEntry[V]{e: e}
+}
+
+// overlayed 获取overlay，不存在时创建
+func (s *shard[K, V]) overlayed() map[K]overlayEntry[V] {
+    if s.overlay == nil {
+        s.overlay = make(map[K]overlayEntry[V])
+    }
+    return s.overlay
+}
+
+// delete 删除数据项并返回被删除数据项的成本之和，需在lock之后调用，data被快照共享时在overlay中记录删除
+func (s *shard[K, V]) delete(keys ...K) (cost int64) {
+    if s.shares == 0 {
+        data := s.writable()
+        for _, key := range keys {
+            if e, ok := data[key]; ok {
+                cost += e.cost
+                delete(data, key)
+            }
+        }
+        s.commit(data)
+        return cost
+    }
+    for _, key := range keys {
+        e, ok := s.current().get(key)
+        if !ok {
+            continue
+        }
+        cost += e.cost
+        s.added--
+        if _, shared := s.data[key]; shared {
+            s.overlayed()[key] = overlayEntry[V]{deleted: true}
+        } else {
+            delete(s.overlay, key)
+        }
+    }
+    return cost
+}
+
+// share 把当前数据共享给快照，需在lock之后调用
+// 之后的修改写入overlay而不修改data，快照持有overlay的副本，写时复制模式下data本身不可修改，无需记录共享
+func (s *shard[K, V]) share() layer[K, V] {
+    if s.cow {
+        return layer[K, V]{base: s.data, size: len(s.data)}
+    }
+    s.shares++
+    l := s.current()
+    l.overlay = maps.Clone(s.overlay)
+    return l
+}
+
+// unshare 快照释放共享的数据，最后一个快照释放后把overlay合并到data，需在lock之后调用
+func (s *shard[K, V]) unshare() {
+    if s.cow || s.shares == 0 {
+        return
+    }
+    s.shares--
+    if s.shares > 0 {
+        return
+    }
+    for k, o := range s.overlay {
+        if o.deleted {
+            delete(s.data, k)
+        } else {
+            s.data[k] = o.e
+        }
+    }
+    s.overlay, s.added = nil, 0
+}
+
+// commit 提交writable返回的数据
+func (s *shard[K, V]) commit(data map[K]entry[V]) {
+    s.data = data
+    if !s.cow {
+        return
+    }
+    if s.staging {
+        s.staged = data
+        return
+    }
+    s.published.Store(&data)
+}
+
+// stage 开始暂存修改，写时复制模式下多次修改只复制一次并一起发布，需在lock之后调用
+func (s *shard[K, V]) stage() {
+    s.staging = true
+}
+
+// publish 结束暂存并发布暂存的修改
+func (s *shard[K, V]) publish() {
+    s.staging = false
+    if s.staged != nil {
+        data := s.staged
+        s.staged = nil
+        s.published.Store(&data)
+    }
+}
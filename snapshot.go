@@ -0,0 +1,121 @@
+package smap
+
+import (
+    "sync"
+)
+
+// Snapshot map在某一时刻的只读快照
+// 创建快照时只共享各分片的数据而不复制，之后的修改暂存在分片的overlay中，快照读取时不加锁
+type Snapshot[K comparable, V any] struct {
+    m    *Map[K, V]
+    now  int64
+    data []layer[K, V]
+    once sync.Once
+}
+
+// Snapshot 创建当前数据的快照，不再使用后应调用Release，把快照存在期间的修改合并回分片
+// 创建快照只复制各分片中尚未合并的修改，快照存在期间被修改和删除的数据会一直保留到Release
+// 快照中的过期判断以创建快照的时刻为准
+func (a *Map[K, V]) Snapshot() *Snapshot[K, V] {
+    for _, s := range a.shards {
+        s.lock()
+    }
+    snap := a.snapshotLocked()
+    for i := len(a.shards) - 1; i >= 0; i-- {
+        a.shards[i].unlock()
+    }
+    return snap
+}
+
+// snapshotLocked 创建快照，需在持有所有分片写锁时调用
+func (a *Map[K, V]) snapshotLocked() *Snapshot[K, V] {
+    snap := &Snapshot[K, V]{
+        m:    a,
+        now:  a.now(),
+        data: make([]layer[K, V], len(a.shards)),
+    }
+    for i, s := range a.shards {
+        snap.data[i] = s.share()
+    }
+    return snap
+}
+
+// Release 释放快照，最后一个快照释放时把修改合并回分片，可重复调用
+// 调用Release后不能再读取快照
+func (snap *Snapshot[K, V]) Release() {
+    snap.once.Do(func() {
+        for _, s := range snap.m.shards {
+            s.lock()
+            s.unshare()
+            s.unlock()
+        }
+        snap.data = nil
+    })
+}
+
+// shard 获取key在快照中所在分片的数据
+func (snap *Snapshot[K, V]) shard(key K) layer[K, V] {
+    if len(snap.data) == 1 {
+        return snap.data[0]
+    }
+    return snap.data[snap.m.index(key)]
+}
+
+// Get 获取value
+func (snap *Snapshot[K, V]) Get(key K) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    if e, ok := lookup(snap.shard(key), key, snap.now); ok {
+        return e.value, true, nil
+    }
+
+    return val, false, err
+}
+
+// Has 检查key是否存在
+func (snap *Snapshot[K, V]) Has(key K) bool {
+    _, ok, _ := snap.Get(key)
+    return ok
+}
+
+// Keys 获取所有key
+func (snap *Snapshot[K, V]) Keys() []K {
+    keys := make([]K, 0)
+    snap.Range(func(key K, _ V) bool {
+        keys = append(keys, key)
+        return true
+    })
+    return keys
+}
+
+// Size 获取数据长度
+func (snap *Snapshot[K, V]) Size() int {
+    size := 0
+    for _, data := range snap.data {
+        size += snap.m.sizeOf(data, snap.now)
+    }
+    return size
+}
+
+// All 获取所有数据的副本
+func (snap *Snapshot[K, V]) All() map[K]V {
+    kvs := make(map[K]V)
+    snap.Range(func(key K, value V) bool {
+        kvs[key] = value
+        return true
+    })
+    return kvs
+}
+
+// Range 遍历快照中的数据，fn返回false时停止遍历，fn内可以调用map的任意方法
+func (snap *Snapshot[K, V]) Range(fn func(key K, value V) bool) {
+    for _, data := range snap.data {
+        for k, e := range data.all() {
+            if e.expired(snap.now) {
+                continue
+            }
+            if !fn(k, e.value) {
+                return
+            }
+        }
+    }
+}
@@ -0,0 +1,124 @@
+package smap
+
+import (
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestMap_Snapshot(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {name: "unsafe", opts: nil},
+        {name: "safe", opts: []Option{WithSafe()}},
+        {name: "sharded", opts: []Option{WithShards(4)}},
+        {name: "cow", opts: []Option{WithCopyOnWrite()}},
+        {name: "lru", opts: []Option{WithSafe(), WithCapacity(10)}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapWith[string, int](tt.opts...)
+            m.Set("a", 1)
+            m.Set("b", 2)
+            snap := m.Snapshot()
+            m.Set("a", 10)
+            m.Remove("b")
+            m.Set("c", 3)
+            want := map[string]int{"a": 1, "b": 2}
+            if got := snap.All(); !reflect.DeepEqual(got, want) {
+                t.Errorf("Snapshot().All() = %v, want %v", got, want)
+            }
+            if v, ok, err := snap.Get("a"); !ok || err != nil || v != 1 {
+                t.Errorf("Snapshot().Get() = %v, %v, %v, want 1", v, ok, err)
+            }
+            if snap.Has("c") || snap.Size() != 2 || len(snap.Keys()) != 2 {
+                t.Errorf("Snapshot() Has(c) = %v, Size() = %d", snap.Has("c"), snap.Size())
+            }
+            want = map[string]int{"a": 10, "c": 3}
+            if got := m.All(); !reflect.DeepEqual(got, want) {
+                t.Errorf("All() = %v, want %v", got, want)
+            }
+            snap.Release()
+            snap.Release()
+        })
+    }
+}
+
+func TestMap_SnapshotRelease(t *testing.T) {
+    m := NewMap[string, int](true)
+    m.Set("a", 1)
+    s := m.shards[0]
+    data, orig := s.data, s.data["a"]
+    snap := m.Snapshot()
+    // 快照存在期间的修改写入overlay，不复制也不修改共享的数据
+    m.Set("a", 2)
+    m.Set("b", 2)
+    m.Remove("a")
+    if reflect.ValueOf(s.data).Pointer() != reflect.ValueOf(data).Pointer() || !reflect.DeepEqual(data, map[string]entry[int]{"a": orig}) {
+        t.Errorf("Set() with live snapshot modified shared data: %v", s.data)
+    }
+    if got := m.All(); !reflect.DeepEqual(got, map[string]int{"b": 2}) || m.Size() != 1 {
+        t.Errorf("All() = %v, Size() = %d, want overlay applied", got, m.Size())
+    }
+    inner := m.Snapshot()
+    m.Set("c", 3)
+    if got := inner.All(); !reflect.DeepEqual(got, map[string]int{"b": 2}) {
+        t.Errorf("nested Snapshot().All() = %v", got)
+    }
+    snap.Release()
+    if s.overlay == nil {
+        t.Errorf("Release() merged overlay while a snapshot is still live")
+    }
+    inner.Release()
+    if s.shares != 0 || s.overlay != nil || s.added != 0 {
+        t.Errorf("Release() shares = %d, overlay = %v, want merged", s.shares, s.overlay)
+    }
+    if got := m.All(); !reflect.DeepEqual(got, map[string]int{"b": 2, "c": 3}) || reflect.ValueOf(s.data).Pointer() != reflect.ValueOf(data).Pointer() {
+        t.Errorf("All() after Release = %v, want merged in place", got)
+    }
+}
+
+func TestMap_SnapshotExpire(t *testing.T) {
+    m := NewMap[string, int]()
+    m.SetWithTTL("a", 1, time.Hour)
+    m.SetWithTTL("b", 2, time.Nanosecond)
+    time.Sleep(time.Millisecond)
+    snap := m.Snapshot()
+    defer snap.Release()
+    if snap.Has("b") || snap.Size() != 1 {
+        t.Errorf("Snapshot() contains expired key, size %d", snap.Size())
+    }
+}
+
+func TestMap_SnapshotConcurrent(t *testing.T) {
+    m := NewMapWith[int, int](WithShards(4))
+    for i := 0; i < 100; i++ {
+        m.Set(i, 0)
+    }
+    var wg sync.WaitGroup
+    wg.Add(2)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 1000; i++ {
+            m.Set(i%100, i)
+        }
+    }()
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 50; i++ {
+            snap := m.Snapshot()
+            if size := snap.Size(); size != 100 {
+                t.Errorf("Snapshot().Size() = %d, want 100", size)
+            }
+            snap.Range(func(k, v int) bool {
+                m.Get(k)
+                return true
+            })
+            snap.Release()
+        }
+    }()
+    wg.Wait()
+}
@@ -0,0 +1,47 @@
+package smap
+
+import (
+    "sync/atomic"
+)
+
+// Stats 命中与淘汰统计
+type Stats struct {
+    // Hits Get命中次数
+    Hits uint64
+    // Misses Get未命中次数
+    Misses uint64
+    // Evictions 因容量、成本或过期被淘汰的数据数量
+    Evictions uint64
+}
+
+// HitRatio 命中率，没有任何Get时返回0
+func (s Stats) HitRatio() float64 {
+    total := s.Hits + s.Misses
+    if total == 0 {
+        return 0
+    }
+    return float64(s.Hits) / float64(total)
+}
+
+// counters map内部使用的统计计数器
+type counters struct {
+    hits      atomic.Uint64
+    misses    atomic.Uint64
+    evictions atomic.Uint64
+}
+
+// Stats 获取命中与淘汰统计
+func (a *Map[K, V]) Stats() Stats {
+    return Stats{
+        Hits:      a.stats.hits.Load(),
+        Misses:    a.stats.misses.Load(),
+        Evictions: a.stats.evictions.Load(),
+    }
+}
+
+// ResetStats 清零统计数据
+func (a *Map[K, V]) ResetStats() {
+    a.stats.hits.Store(0)
+    a.stats.misses.Store(0)
+    a.stats.evictions.Store(0)
+}
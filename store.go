@@ -0,0 +1,291 @@
+package smap
+
+import (
+    "errors"
+    "sync"
+    "time"
+)
+
+// ErrNotFound 加载的key不存在，loader返回该error时GetOrLoad返回ok为false且不返回error
+var ErrNotFound = errors.New("smap: key not found")
+
+// Store 持久化存储
+type Store[K comparable, V any] interface {
+    // Write 写入k/v
+    Write(key K, value V) error
+    // Delete 删除key
+    Delete(key K) error
+    // Load 读取key，ok表示key是否存在
+    Load(key K) (value V, ok bool, err error)
+}
+
+// BatchStore 持久化存储可选实现的接口，延迟写入时一次提交一批修改
+type BatchStore[K comparable, V any] interface {
+    WriteBatch(writes map[K]V, deletes []K) error
+}
+
+// WriteMode 持久化写入模式
+type WriteMode int
+
+const (
+    // WriteThrough 同步写入，写入存储失败时写操作返回error且不修改内存数据
+    WriteThrough WriteMode = iota + 1
+    // WriteBehind 延迟写入，修改先合并在内存中，由后台协程定期批量写入存储
+    WriteBehind
+)
+
+// pendingOp 延迟写入中等待写入存储的修改
+type pendingOp[V any] struct {
+    value  V
+    delete bool
+}
+
+// storeSync 把map的修改同步到持久化存储
+type storeSync[K comparable, V any] struct {
+    store   Store[K, V]
+    mode    WriteMode
+    batch   int
+    onError func(key K, err error)
+    // mutex 保护pending和closed，flushing 保证同一时刻只有一次写入存储，避免同一个key的修改乱序
+    mutex    sync.Mutex
+    pending  map[K]pendingOp[V]
+    closed   bool
+    flushing sync.Mutex
+    kick     chan struct{}
+}
+
+// initStore 按配置初始化持久化存储，延迟写入时启动后台写入协程
+func (a *Map[K, V]) initStore(o *options) {
+    ss := &storeSync[K, V]{
+        store:   assertOption[Store[K, V]]("store", o.store),
+        mode:    o.writeMode,
+        batch:   o.flushBatch,
+        pending: make(map[K]pendingOp[V]),
+        kick:    make(chan struct{}, 1),
+    }
+    if ss.mode != WriteBehind {
+        ss.mode = WriteThrough
+    }
+    if o.onStoreError != nil {
+        ss.onError = assertOption[func(K, error)]("store error handler", o.onStoreError)
+    }
+    a.store = ss
+    if a.loader == nil {
+        a.loader = ss.load
+    }
+    if ss.mode == WriteBehind {
+        interval := o.flushInterval
+        if interval <= 0 {
+            interval = time.Second
+        }
+        a.wg.Add(1)
+        go a.flusher(interval)
+    }
+}
+
+// write 同步写入或记录待写入的k/v
+func (ss *storeSync[K, V]) write(key K, value V) error {
+    if ss.mode == WriteThrough {
+        return ss.store.Write(key, value)
+    }
+    return ss.enqueue(key, pendingOp[V]{value: value})
+}
+
+// remove 同步删除或记录待删除的key
+func (ss *storeSync[K, V]) remove(key K) error {
+    if ss.mode == WriteThrough {
+        return ss.store.Delete(key)
+    }
+    return ss.enqueue(key, pendingOp[V]{delete: true})
+}
+
+// load 从存储加载key，作为未配置loader时的默认加载函数
+func (ss *storeSync[K, V]) load(key K) (V, error) {
+    v, ok, err := ss.store.Load(key)
+    if err == nil && !ok {
+        err = ErrNotFound
+    }
+    return v, err
+}
+
+// enqueue 记录待写入的修改，同一个key的多次修改只保留最后一次，关闭后返回ErrClosed
+func (ss *storeSync[K, V]) enqueue(key K, op pendingOp[V]) error {
+    ss.mutex.Lock()
+    if ss.closed {
+        ss.mutex.Unlock()
+        return ErrClosed
+    }
+    ss.pending[key] = op
+    n := len(ss.pending)
+    ss.mutex.Unlock()
+    if ss.batch > 0 && n >= ss.batch {
+        select {
+        case ss.kick <- struct{}{}:
+        default:
+        }
+    }
+    return nil
+}
+
+// close 停止接受新的修改并把待写入的修改写入存储
+func (ss *storeSync[K, V]) close() error {
+    ss.mutex.Lock()
+    ss.closed = true
+    ss.mutex.Unlock()
+    return ss.flush()
+}
+
+// flush 把所有待写入的修改写入存储
+// 写入失败的修改在没有更新的修改时重新放回等待下次写入，并通过错误回调通知
+func (ss *storeSync[K, V]) flush() error {
+    if ss.mode != WriteBehind {
+        return nil
+    }
+    ss.flushing.Lock()
+    defer ss.flushing.Unlock()
+    ss.mutex.Lock()
+    pending := ss.pending
+    ss.pending = make(map[K]pendingOp[V])
+    ss.mutex.Unlock()
+    if len(pending) == 0 {
+        return nil
+    }
+
+    failed := ss.apply(pending)
+    if len(failed) == 0 {
+        return nil
+    }
+    errs := make([]error, 0, len(failed))
+    ss.mutex.Lock()
+    for key, err := range failed {
+        if _, newer := ss.pending[key]; !newer {
+            ss.pending[key] = pending[key]
+        }
+        errs = append(errs, err)
+    }
+    ss.mutex.Unlock()
+    if ss.onError != nil {
+        for key, err := range failed {
+            ss.onError(key, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// apply 写入一批修改，返回写入失败的key
+func (ss *storeSync[K, V]) apply(pending map[K]pendingOp[V]) map[K]error {
+    failed := make(map[K]error)
+    if bs, ok := ss.store.(BatchStore[K, V]); ok {
+        writes := make(map[K]V)
+        deletes := make([]K, 0)
+        for key, op := range pending {
+            if op.delete {
+                deletes = append(deletes, key)
+            } else {
+                writes[key] = op.value
+            }
+        }
+        if err := bs.WriteBatch(writes, deletes); err != nil {
+            for key := range pending {
+                failed[key] = err
+            }
+        }
+        return failed
+    }
+    for key, op := range pending {
+        var err error
+        if op.delete {
+            err = ss.store.Delete(key)
+        } else {
+            err = ss.store.Write(key, op.value)
+        }
+        if err != nil {
+            failed[key] = err
+        }
+    }
+    return failed
+}
+
+// flusher 延迟写入的后台协程
+func (a *Map[K, V]) flusher(interval time.Duration) {
+    defer a.wg.Done()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            a.store.flush()
+        case <-a.store.kick:
+            a.store.flush()
+        case <-a.closing:
+            return
+        }
+    }
+}
+
+// Flush 立即把延迟写入中尚未写入的修改写入持久化存储，同步写入模式下直接返回nil
+func (a *Map[K, V]) Flush() error {
+    if a.store == nil {
+        return nil
+    }
+    return a.store.flush()
+}
+
+// MemoryStore 基于内存的Store实现，可用于测试或作为持久化存储的替身
+type MemoryStore[K comparable, V any] struct {
+    mutex sync.RWMutex
+    data  map[K]V
+}
+
+// NewMemoryStore 创建一个MemoryStore对象
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+    return &MemoryStore[K, V]{data: make(map[K]V)}
+}
+
+// Write 写入k/v
+func (s *MemoryStore[K, V]) Write(key K, value V) error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    s.data[key] = value
+    return nil
+}
+
+// Delete 删除key
+func (s *MemoryStore[K, V]) Delete(key K) error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    delete(s.data, key)
+    return nil
+}
+
+// Load 读取key
+func (s *MemoryStore[K, V]) Load(key K) (value V, ok bool, err error) {
+    s.mutex.RLock()
+    defer s.mutex.RUnlock()
+    value, ok = s.data[key]
+    return value, ok, nil
+}
+
+// WriteBatch 一次写入一批修改
+func (s *MemoryStore[K, V]) WriteBatch(writes map[K]V, deletes []K) error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    for k, v := range writes {
+        s.data[k] = v
+    }
+    for _, k := range deletes {
+        delete(s.data, k)
+    }
+    return nil
+}
+
+// All 获取存储中所有数据的副本
+func (s *MemoryStore[K, V]) All() map[K]V {
+    s.mutex.RLock()
+    defer s.mutex.RUnlock()
+    kvs := make(map[K]V, len(s.data))
+    for k, v := range s.data {
+        kvs[k] = v
+    }
+    return kvs
+}
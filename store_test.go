@@ -0,0 +1,201 @@
+package smap
+
+import (
+    "errors"
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+)
+
+// countingStore 记录调用次数并可注入错误的Store，不支持批量写入
+type countingStore struct {
+    mem    *MemoryStore[string, int]
+    mutex  sync.Mutex
+    writes int
+    fail   error
+}
+
+func newCountingStore() *countingStore {
+    return &countingStore{mem: NewMemoryStore[string, int]()}
+}
+
+func (s *countingStore) Write(key string, value int) error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    if s.fail != nil {
+        return s.fail
+    }
+    s.writes++
+    return s.mem.Write(key, value)
+}
+
+func (s *countingStore) Delete(key string) error {
+    return s.mem.Delete(key)
+}
+
+func (s *countingStore) Load(key string) (int, bool, error) {
+    return s.mem.Load(key)
+}
+
+func (s *countingStore) All() map[string]int {
+    return s.mem.All()
+}
+
+func (s *countingStore) setFail(err error) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    s.fail = err
+}
+
+func (s *countingStore) count() int {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return s.writes
+}
+
+func TestMap_WriteThrough(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {name: "unsafe", opts: nil},
+        {name: "safe", opts: []Option{WithSafe()}},
+        {name: "sharded", opts: []Option{WithShards(4)}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store := NewMemoryStore[string, int]()
+            m := NewMapWith[string, int](append(tt.opts, WithStore[string, int](store, WriteThrough))...)
+            m.Set("a", 1)
+            m.Set("b", 2)
+            m.Swap("c", 3)
+            m.Compute("a", func(old int, exists bool) (int, bool) {
+                return old + 10, true
+            })
+            m.Remove("b")
+            want := map[string]int{"a": 11, "c": 3}
+            if got := store.All(); !reflect.DeepEqual(got, want) {
+                t.Errorf("store = %v, want %v", got, want)
+            }
+        })
+    }
+}
+
+func TestMap_WriteThrough_error(t *testing.T) {
+    errStore := errors.New("store unavailable")
+    store := newCountingStore()
+    m := NewMapWith[string, int](WithSafe(), WithStore[string, int](store, WriteThrough))
+    m.Set("a", 1)
+    store.setFail(errStore)
+    if err := m.Set("a", 2); !errors.Is(err, errStore) {
+        t.Errorf("Set() error = %v, want %v", err, errStore)
+    }
+    if v, _, _ := m.Get("a"); v != 1 {
+        t.Errorf("Get() = %v, want unchanged value 1", v)
+    }
+}
+
+func TestMap_WriteThrough_evict(t *testing.T) {
+    store := NewMemoryStore[string, int]()
+    m := NewMapWith[string, int](WithCapacity(1), WithStore[string, int](store, WriteThrough))
+    m.Set("a", 1)
+    m.Set("b", 2)
+    if m.Has("a") {
+        t.Errorf("Has(a) = true, want evicted")
+    }
+    if got := store.All(); len(got) != 2 {
+        t.Errorf("store = %v, evicted key should stay in store", got)
+    }
+    // 被淘汰的key从store重新加载，加载时不会再次写入store
+    if v, ok, err := m.GetOrLoad("a", nil); v != 1 || !ok || err != nil {
+        t.Errorf("GetOrLoad() = %v, %v, %v", v, ok, err)
+    }
+    if v, ok, err := m.GetOrLoad("x", nil); v != 0 || ok || err != nil {
+        t.Errorf("GetOrLoad() missing key = %v, %v, %v", v, ok, err)
+    }
+}
+
+func TestMap_WriteBehind(t *testing.T) {
+    store := newCountingStore()
+    m := NewMapWith[string, int](WithSafe(), WithStore[string, int](store, WriteBehind), WithFlushInterval(time.Hour))
+    for i := 0; i < 10; i++ {
+        m.Set("a", i)
+    }
+    m.Set("b", 1)
+    m.Remove("b")
+    if got := store.count(); got != 0 {
+        t.Errorf("store writes before flush = %v, want 0", got)
+    }
+    if err := m.Flush(); err != nil {
+        t.Fatalf("Flush() error = %v", err)
+    }
+    if got := store.All(); !reflect.DeepEqual(got, map[string]int{"a": 9}) {
+        t.Errorf("store = %v, want coalesced writes", got)
+    }
+    m.Set("c", 3)
+    if err := m.Close(); err != nil {
+        t.Fatalf("Close() error = %v", err)
+    }
+    if _, ok, _ := store.Load("c"); !ok {
+        t.Errorf("Close() did not flush pending writes")
+    }
+    if err := m.Set("d", 4); !errors.Is(err, ErrClosed) {
+        t.Errorf("Set() after Close error = %v, want %v", err, ErrClosed)
+    }
+    if err := m.Remove("c"); !errors.Is(err, ErrClosed) {
+        t.Errorf("Remove() after Close error = %v, want %v", err, ErrClosed)
+    }
+    if m.Has("d") || !m.Has("c") {
+        t.Errorf("writes after Close changed memory: %v", m.All())
+    }
+}
+
+func TestMap_WriteBehind_error(t *testing.T) {
+    errStore := errors.New("store unavailable")
+    store := newCountingStore()
+    failed := make(map[string]error)
+    var mutex sync.Mutex
+    m := NewMapWith[string, int](
+        WithStore[string, int](store, WriteBehind),
+        WithFlushInterval(time.Hour),
+        WithStoreErrorHandler(func(key string, err error) {
+            mutex.Lock()
+            defer mutex.Unlock()
+            failed[key] = err
+        }),
+    )
+    defer m.Close()
+    store.setFail(errStore)
+    m.Set("a", 1)
+    if err := m.Flush(); !errors.Is(err, errStore) {
+        t.Errorf("Flush() error = %v, want %v", err, errStore)
+    }
+    if !errors.Is(failed["a"], errStore) {
+        t.Errorf("error handler = %v, want %v", failed, errStore)
+    }
+    store.setFail(nil)
+    if err := m.Flush(); err != nil {
+        t.Errorf("Flush() retry error = %v", err)
+    }
+    if _, ok, _ := store.Load("a"); !ok {
+        t.Errorf("failed write was not retried")
+    }
+}
+
+func TestMap_WriteBehind_batch(t *testing.T) {
+    store := NewMemoryStore[string, int]()
+    m := NewMapWith[string, int](WithSafe(), WithStore[string, int](store, WriteBehind),
+        WithFlushInterval(time.Hour), WithFlushBatch(2))
+    defer m.Close()
+    m.Set("a", 1)
+    m.Set("b", 2)
+    deadline := time.Now().Add(time.Second)
+    for time.Now().Before(deadline) {
+        if len(store.All()) == 2 {
+            return
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+    t.Errorf("batch flush not triggered, store = %v", store.All())
+}
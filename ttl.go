@@ -0,0 +1,94 @@
+package smap
+
+import (
+    "errors"
+    "time"
+)
+
+// SetWithTTL 设置k/v并指定过期时间，ttl<=0表示永不过期
+// 已过期的key在Get/Has时视为不存在并被删除，也可由后台清理协程统一清理
+func (a *Map[K, V]) SetWithTTL(key K, value V, ttl time.Duration) (err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if ttl > 0 {
+        a.expiring.Store(true)
+    }
+
+    return a.storeLocked(s, key, a.newEntryTTL(value, ttl))
+}
+
+// TTL 获取key的剩余过期时间，ttl为0表示永不过期
+func (a *Map[K, V]) TTL(key K) (ttl time.Duration, ok bool, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    e, ok := a.get(s, key, a.now())
+    if !ok || e.expire == 0 {
+        return 0, ok, nil
+    }
+    return time.Duration(e.expire - time.Now().UnixNano()), true, err
+}
+
+// DeleteExpired 清理所有已过期的数据
+func (a *Map[K, V]) DeleteExpired() {
+    now := a.now()
+    if now == 0 {
+        return
+    }
+    for _, s := range a.shards {
+        a.deleteExpired(s, now)
+    }
+}
+
+// deleteExpired 清理单个分片中已过期的数据
+func (a *Map[K, V]) deleteExpired(s *shard[K, V], now int64) {
+    s.lock()
+    defer a.unlock(s)
+    keys := make([]K, 0)
+    for k, e := range s.current().all() {
+        if e.expired(now) {
+            keys = append(keys, k)
+        }
+    }
+    if len(keys) > 0 {
+        a.evictLocked(s, EvictExpired, keys...)
+    }
+}
+
+// janitor 后台清理协程
+func (a *Map[K, V]) janitor(interval time.Duration) {
+    defer a.wg.Done()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            a.DeleteExpired()
+        case <-a.closing:
+            return
+        }
+    }
+}
+
+// ErrClosed map已关闭，配置了延迟写入或预写日志时关闭后的写操作返回该error，阻塞中的WaitFor/WaitUntil也返回该error
+var ErrClosed = errors.New("smap: map is closed")
+
+// Close 停止后台协程并取消所有订阅（包括WaitFor/WaitUntil的等待），配置了延迟写入时把尚未写入的数据写入持久化存储，可重复调用
+// 通过OpenMap打开的map会同步并关闭预写日志；配置了延迟写入或预写日志时，关闭后的写操作返回ErrClosed
+func (a *Map[K, V]) Close() (err error) {
+    a.closed.Do(func() {
+        a.unsubscribeAll()
+        if a.closing != nil {
+            close(a.closing)
+        }
+        a.wg.Wait()
+        if a.store != nil {
+            err = a.store.close()
+        }
+        if a.wal != nil {
+            err = errors.Join(err, a.wal.close())
+        }
+    })
+    return err
+}
@@ -0,0 +1,117 @@
+package smap
+
+import (
+    "testing"
+    "time"
+)
+
+func TestMap_SetWithTTL(t *testing.T) {
+    tests := []struct {
+        name   string
+        a      *MapStrAny
+        ttl    time.Duration
+        wait   time.Duration
+        wantOk bool
+    }{
+        {name: "unsafe alive", a: NewMapStrAny(), ttl: time.Hour, wantOk: true},
+        {name: "unsafe expired", a: NewMapStrAny(), ttl: 10 * time.Millisecond, wait: 20 * time.Millisecond, wantOk: false},
+        {name: "unsafe never", a: NewMapStrAny(), ttl: 0, wait: 10 * time.Millisecond, wantOk: true},
+        {name: "safe alive", a: NewMapStrAny(true), ttl: time.Hour, wantOk: true},
+        {name: "safe expired", a: NewMapStrAny(true), ttl: 10 * time.Millisecond, wait: 20 * time.Millisecond, wantOk: false},
+        {name: "sharded expired", a: NewMapStrAnyWith(WithShards(4)), ttl: 10 * time.Millisecond, wait: 20 * time.Millisecond, wantOk: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := tt.a.SetWithTTL("token", "v", tt.ttl); err != nil {
+                t.Fatalf("SetWithTTL() error = %v", err)
+            }
+            tt.a.Set("other", 1)
+            time.Sleep(tt.wait)
+            if _, ok, err := tt.a.Get("token"); ok != tt.wantOk || err != nil {
+                t.Errorf("Get() ok = %v, err = %v, want %v", ok, err, tt.wantOk)
+            }
+            if got := tt.a.Has("token"); got != tt.wantOk {
+                t.Errorf("Has() = %v, want %v", got, tt.wantOk)
+            }
+            wantSize := 1
+            if tt.wantOk {
+                wantSize = 2
+            }
+            if got := tt.a.Size(); got != wantSize {
+                t.Errorf("Size() = %v, want %v", got, wantSize)
+            }
+            if got := len(tt.a.Keys()); got != wantSize {
+                t.Errorf("Keys() len = %v, want %v", got, wantSize)
+            }
+        })
+    }
+}
+
+func TestMap_TTL(t *testing.T) {
+    m := NewMapWith[string, int](WithTTL(time.Hour))
+    m.Set("a", 1)
+    m.SetWithTTL("b", 2, 0)
+    if ttl, ok, err := m.TTL("a"); !ok || err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+        t.Errorf("TTL(a) = %v, %v, %v", ttl, ok, err)
+    }
+    if ttl, ok, err := m.TTL("b"); !ok || err != nil || ttl != 0 {
+        t.Errorf("TTL(b) = %v, %v, %v", ttl, ok, err)
+    }
+    if _, ok, _ := m.TTL("c"); ok {
+        t.Errorf("TTL(c) ok = %v, want false", ok)
+    }
+}
+
+func TestMap_defaultTTL(t *testing.T) {
+    m := NewMapWith[string, int](WithTTL(10 * time.Millisecond))
+    m.Set("a", 1)
+    m.GetOrSet("b", 2)
+    time.Sleep(20 * time.Millisecond)
+    if m.Has("a") || m.Has("b") {
+        t.Errorf("default ttl not applied")
+    }
+    if _, loaded, _ := m.GetOrSet("a", 3); loaded {
+        t.Errorf("GetOrSet() loaded expired value")
+    }
+}
+
+func TestMap_janitor(t *testing.T) {
+    m := NewMapWith[string, int](WithJanitor(5 * time.Millisecond))
+    defer m.Close()
+    if !m.safe {
+        t.Errorf("WithJanitor() safe = false, want true")
+    }
+    m.SetWithTTL("a", 1, 5*time.Millisecond)
+    m.Set("b", 2)
+    deadline := time.Now().Add(time.Second)
+    for time.Now().Before(deadline) {
+        m.shards[0].rlock()
+        n := m.shards[0].view().size
+        m.shards[0].runlock()
+        if n == 1 {
+            return
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+    t.Errorf("janitor did not remove expired entries")
+}
+
+func TestMap_Close(t *testing.T) {
+    tests := []struct {
+        name string
+        a    *Map[string, int]
+    }{
+        {name: "without janitor", a: NewMap[string, int]()},
+        {name: "with janitor", a: NewMapWith[string, int](WithJanitor(time.Millisecond))},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := tt.a.Close(); err != nil {
+                t.Errorf("Close() error = %v", err)
+            }
+            if err := tt.a.Close(); err != nil {
+                t.Errorf("Close() second call error = %v", err)
+            }
+        })
+    }
+}
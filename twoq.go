@@ -0,0 +1,95 @@
+package smap
+
+import (
+    "container/list"
+)
+
+// twoQueue 2Q淘汰策略
+// 新key先进入先进先出的in队列，再次被访问后进入LRU管理的hot队列；
+// 从in队列淘汰的key记录在只存key的ghost队列中，在ghost中命中的key直接进入hot队列
+type twoQueue[K comparable] struct {
+    in    *list.List
+    hot   *list.List
+    ghost *list.List
+    items map[K]*list.Element
+    // where 记录key所在的队列
+    where map[K]*list.List
+}
+
+// New2QPolicy 创建2Q淘汰策略，一次性扫描访问的key只会停留在in队列中而不会挤出热点数据
+// in队列占常驻key的1/4，ghost队列最多记录常驻key数量1/2的已淘汰key
+func New2QPolicy[K comparable]() EvictionPolicy[K] {
+    return &twoQueue[K]{
+        in:    list.New(),
+        hot:   list.New(),
+        ghost: list.New(),
+        items: make(map[K]*list.Element),
+        where: make(map[K]*list.List),
+    }
+}
+
+// Add 记录写入的key
+func (q *twoQueue[K]) Add(key K, cost int64) {
+    switch q.where[key] {
+    case q.hot, q.in:
+        q.Access(key)
+    case q.ghost:
+        q.ghost.Remove(q.items[key])
+        q.push(q.hot, key)
+    default:
+        q.push(q.in, key)
+    }
+}
+
+// Access 记录key被读取，in队列中的key移入hot队列
+func (q *twoQueue[K]) Access(key K) {
+    switch q.where[key] {
+    case q.hot:
+        q.hot.MoveToFront(q.items[key])
+    case q.in:
+        q.in.Remove(q.items[key])
+        q.push(q.hot, key)
+    }
+}
+
+// Remove 移除常驻的key，ghost队列中的记录保留
+func (q *twoQueue[K]) Remove(key K) {
+    if l, ok := q.where[key]; ok && l != q.ghost {
+        l.Remove(q.items[key])
+        delete(q.items, key)
+        delete(q.where, key)
+    }
+}
+
+// Evict in队列超出配额时淘汰in队列最早的key并记入ghost，否则淘汰hot队列最久未访问的key
+func (q *twoQueue[K]) Evict() (key K, ok bool) {
+    resident := q.in.Len() + q.hot.Len()
+    if resident == 0 {
+        return key, false
+    }
+    if q.in.Len() > 0 && (q.in.Len() > max(1, resident/4) || q.hot.Len() == 0) {
+        el := q.in.Back()
+        key = el.Value.(K)
+        q.in.Remove(el)
+        q.push(q.ghost, key)
+        for q.ghost.Len() > max(1, resident/2) {
+            old := q.ghost.Back()
+            q.ghost.Remove(old)
+            delete(q.items, old.Value.(K))
+            delete(q.where, old.Value.(K))
+        }
+        return key, true
+    }
+    el := q.hot.Back()
+    key = el.Value.(K)
+    q.hot.Remove(el)
+    delete(q.items, key)
+    delete(q.where, key)
+    return key, true
+}
+
+// push 把key放入队列头部
+func (q *twoQueue[K]) push(l *list.List, key K) {
+    q.items[key] = l.PushFront(key)
+    q.where[key] = l
+}
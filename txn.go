@@ -0,0 +1,177 @@
+package smap
+
+// Tx 事务，只能在Txn的回调中使用
+// 事务内的修改先写入缓冲区，回调返回nil后一次性提交
+type Tx[K comparable, V any] struct {
+    m   *Map[K, V]
+    now int64
+    // writes 事务内修改过的key，order 修改的先后顺序
+    writes map[K]txWrite[V]
+    order  []K
+}
+
+// txWrite 事务内对单个key的修改
+type txWrite[V any] struct {
+    e      entry[V]
+    remove bool
+}
+
+// txUndo 提交失败时用于恢复的修改前数据
+type txUndo[K comparable, V any] struct {
+    s      *shard[K, V]
+    key    K
+    old    entry[V]
+    exists bool
+}
+
+// Txn 在事务中执行fn，fn返回nil时原子地提交所有修改，返回error或panic时丢弃所有修改
+// 事务期间持有所有分片的写锁，其他读写操作会被阻塞，fn中不能调用当前map的方法
+// 提交时写入持久化存储失败会恢复内存中已修改的key并返回error，已写入存储的数据不会回滚
+func (a *Map[K, V]) Txn(fn func(tx *Tx[K, V]) error) (err error) {
+    defer errorRecover(&err)
+    a.lockAll()
+    defer a.unlockAll()
+    tx := &Tx[K, V]{m: a, now: a.now(), writes: make(map[K]txWrite[V])}
+    if err := fn(tx); err != nil {
+        return err
+    }
+
+    return a.commitTx(tx)
+}
+
+// lockAll 按顺序对所有分片加写锁，写时复制模式下暂存修改直到释放锁
+func (a *Map[K, V]) lockAll() {
+    for _, s := range a.shards {
+        s.lock()
+        s.stage()
+    }
+}
+
+// unlockAll 发布暂存的修改并释放所有分片的写锁
+func (a *Map[K, V]) unlockAll() {
+    for i := len(a.shards) - 1; i >= 0; i-- {
+        a.shards[i].publish()
+        a.unlock(a.shards[i])
+    }
+}
+
+// commitTx 提交事务内的修改，需在持有所有分片写锁时调用
+// 开启预写日志时所有修改作为一条批量记录写入日志，写入失败时恢复内存中的修改
+// 变更事件在提交成功后才发送，提交失败时订阅者不会看到事务内的修改
+func (a *Map[K, V]) commitTx(tx *Tx[K, V]) (err error) {
+    a.holdEvents()
+    defer a.releaseEvents(func(ev Event[K, V]) bool {
+        // 提交失败时丢弃事务内key的事件，淘汰其他key的事件仍然发送
+        _, written := tx.writes[ev.Key]
+        return err == nil || (ev.Op == OpEvict && !written)
+    })
+    if a.wal != nil {
+        a.wal.begin()
+        // 提交失败或panic时丢弃尚未写入日志的记录，提交成功后为空操作
+        defer a.wal.abort()
+    }
+    undo := make([]txUndo[K, V], 0, len(tx.order))
+    for _, key := range tx.order {
+        w := tx.writes[key]
+        s := a.shard(key)
+        old, exists := s.current().get(key)
+        undo = append(undo, txUndo[K, V]{s: s, key: key, old: old, exists: exists})
+        if w.remove {
+            err = a.removeLocked(s, key)
+        } else {
+            err = a.storeLocked(s, key, w.e)
+        }
+        if err != nil {
+            a.rollbackTx(undo)
+            return err
+        }
+    }
+    if a.wal != nil {
+        if err = a.wal.commit(); err != nil {
+            a.rollbackTx(undo)
+            return err
+        }
+    }
+    return nil
+}
+
+// rollbackTx 按相反顺序恢复已提交的修改
+func (a *Map[K, V]) rollbackTx(undo []txUndo[K, V]) {
+    for i := len(undo) - 1; i >= 0; i-- {
+        u := undo[i]
+        if u.exists {
+            a.cacheLocked(u.s, u.key, u.old)
+        } else {
+            a.dropLocked(u.s, u.key)
+        }
+    }
+}
+
+// Get 获取value，优先读取事务内的修改
+func (tx *Tx[K, V]) Get(key K) (val V, ok bool, err error) {
+    defer errorRecover(&err)
+    if w, found := tx.writes[key]; found {
+        if w.remove {
+            return val, false, nil
+        }
+        return w.e.value, true, nil
+    }
+    e, ok := lookup(tx.m.shard(key).current(), key, tx.now)
+    if !ok {
+        return val, false, nil
+    }
+
+    return e.value, true, err
+}
+
+// Has 检查key是否存在，优先读取事务内的修改
+func (tx *Tx[K, V]) Has(key K) bool {
+    _, ok, _ := tx.Get(key)
+    return ok
+}
+
+// Keys 获取所有key，包含事务内的修改
+func (tx *Tx[K, V]) Keys() []K {
+    keys := make([]K, 0)
+    for _, s := range tx.m.shards {
+        for k, e := range s.current().all() {
+            if _, found := tx.writes[k]; !found && !e.expired(tx.now) {
+                keys = append(keys, k)
+            }
+        }
+    }
+    for _, k := range tx.order {
+        if !tx.writes[k].remove {
+            keys = append(keys, k)
+        }
+    }
+    return keys
+}
+
+// Set 在事务内设置k/v，配置了默认过期时间时使用默认过期时间
+func (tx *Tx[K, V]) Set(key K, value V) (err error) {
+    defer errorRecover(&err)
+    tx.m.shard(key)
+    tx.write(key, txWrite[V]{e: tx.m.newEntry(value)})
+
+    return err
+}
+
+// Remove 在事务内移除单个或多个key
+func (tx *Tx[K, V]) Remove(keys ...K) (err error) {
+    defer errorRecover(&err)
+    for _, key := range keys {
+        tx.m.shard(key)
+        tx.write(key, txWrite[V]{remove: true})
+    }
+
+    return err
+}
+
+// write 记录事务内的修改
+func (tx *Tx[K, V]) write(key K, w txWrite[V]) {
+    if _, ok := tx.writes[key]; !ok {
+        tx.order = append(tx.order, key)
+    }
+    tx.writes[key] = w
+}
@@ -0,0 +1,140 @@
+package smap
+
+import (
+    "errors"
+    "reflect"
+    "sync"
+    "testing"
+)
+
+func TestMap_Txn(t *testing.T) {
+    errAbort := errors.New("abort")
+    tests := []struct {
+        name    string
+        fn      func(tx *Tx[string, int]) error
+        want    map[string]int
+        wantErr bool
+    }{
+        {name: "commit", fn: func(tx *Tx[string, int]) error {
+            v, _, _ := tx.Get("a")
+            tx.Set("a", v+10)
+            tx.Set("c", 3)
+            return tx.Remove("b")
+        }, want: map[string]int{"a": 11, "c": 3}},
+        {name: "read own writes", fn: func(tx *Tx[string, int]) error {
+            tx.Set("c", 3)
+            tx.Remove("a")
+            if v, ok, _ := tx.Get("c"); !ok || v != 3 {
+                return errAbort
+            }
+            if tx.Has("a") || !tx.Has("b") {
+                return errAbort
+            }
+            tx.Set("a", 5)
+            return nil
+        }, want: map[string]int{"a": 5, "b": 2, "c": 3}},
+        {name: "error", fn: func(tx *Tx[string, int]) error {
+            tx.Set("a", 100)
+            tx.Remove("b")
+            return errAbort
+        }, want: map[string]int{"a": 1, "b": 2}, wantErr: true},
+        {name: "panic", fn: func(tx *Tx[string, int]) error {
+            tx.Set("a", 100)
+            panic("test")
+        }, want: map[string]int{"a": 1, "b": 2}, wantErr: true},
+    }
+    for _, opts := range [][]Option{nil, {WithSafe()}, {WithShards(4)}, {WithCopyOnWrite()}} {
+        for _, tt := range tests {
+            t.Run(tt.name, func(t *testing.T) {
+                m := NewMapWith[string, int](opts...)
+                m.Set("a", 1)
+                m.Set("b", 2)
+                if err := m.Txn(tt.fn); (err != nil) != tt.wantErr {
+                    t.Errorf("Txn() error = %v, wantErr %v", err, tt.wantErr)
+                }
+                if got := m.All(); !reflect.DeepEqual(got, tt.want) {
+                    t.Errorf("Txn() data = %v, want %v", got, tt.want)
+                }
+                if err := m.Set("d", 4); err != nil {
+                    t.Errorf("Set() after Txn error = %v", err)
+                }
+            })
+        }
+    }
+}
+
+func TestMap_TxnStoreRollback(t *testing.T) {
+    store := newCountingStore()
+    m := NewMapWith[string, int](WithStore[string, int](store, WriteThrough))
+    m.Set("a", 1)
+    events, _ := m.Watch()
+    store.setFail(errors.New("store down"))
+    err := m.Txn(func(tx *Tx[string, int]) error {
+        tx.Remove("a")
+        tx.Set("b", 2)
+        return nil
+    })
+    if err == nil {
+        t.Errorf("Txn() error = nil, want store error")
+    }
+    if got, want := m.All(), map[string]int{"a": 1}; !reflect.DeepEqual(got, want) {
+        t.Errorf("Txn() data = %v, want %v", got, want)
+    }
+    if len(events) != 0 {
+        t.Errorf("Txn() rolled back but sent %d events, first %+v", len(events), <-events)
+    }
+
+    store.setFail(nil)
+    m.Txn(func(tx *Tx[string, int]) error {
+        tx.Remove("a")
+        return tx.Set("b", 2)
+    })
+    want := []Event[string, int]{{Op: OpRemove, Key: "a", Old: 1, HasOld: true}, {Op: OpSet, Key: "b", New: 2}}
+    for _, w := range want {
+        if ev := <-events; ev != w {
+            t.Errorf("Txn() event = %+v, want %+v", ev, w)
+        }
+    }
+}
+
+func TestMap_TxnAtomic(t *testing.T) {
+    for _, opts := range [][]Option{{WithShards(8)}, {WithCopyOnWrite()}} {
+        m := NewMapWith[int, int](opts...)
+        for i := 0; i < 10; i++ {
+            m.Set(i, 10)
+        }
+        var wg sync.WaitGroup
+        stop := make(chan struct{})
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < 200; i++ {
+                m.Txn(func(tx *Tx[int, int]) error {
+                    from, to := i%10, (i+3)%10
+                    a, _, _ := tx.Get(from)
+                    b, _, _ := tx.Get(to)
+                    tx.Set(from, a-1)
+                    tx.Set(to, b+1)
+                    return nil
+                })
+            }
+            close(stop)
+        }()
+        for done := false; !done; {
+            select {
+            case <-stop:
+                done = true
+            default:
+            }
+            total := 0
+            m.SnapshotRange(func(k, v int) bool {
+                total += v
+                return true
+            })
+            if total != 100 {
+                t.Fatalf("SnapshotRange() total = %d, want 100", total)
+            }
+        }
+        wg.Wait()
+    }
+}
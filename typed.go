@@ -0,0 +1,350 @@
+package smap
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "reflect"
+    "strconv"
+    "time"
+)
+
+// ErrMissing Must*方法获取的key不存在
+var ErrMissing = errors.New("smap: key not exists")
+
+// ConvertError 类型转换失败的错误
+type ConvertError struct {
+    Key   string
+    Value interface{}
+    // Type 目标类型
+    Type string
+    Err  error
+}
+
+// Error 错误信息
+func (e *ConvertError) Error() string {
+    msg := fmt.Sprintf("smap: key %q: cannot convert %T(%v) to %s", e.Key, e.Value, e.Value, e.Type)
+    if e.Err != nil {
+        msg += ": " + e.Err.Error()
+    }
+    return msg
+}
+
+// Unwrap 返回底层错误
+func (e *ConvertError) Unwrap() error {
+    return e.Err
+}
+
+// GetString 获取value并转换为string，数值和bool会格式化为字符串
+func (a *MapStrAny) GetString(key string) (string, bool, error) {
+    return getAs(a, key, "string", toString)
+}
+
+// GetInt 获取value并转换为int，支持各类整数、没有小数部分的浮点数及数字字符串
+func (a *MapStrAny) GetInt(key string) (int, bool, error) {
+    return getAs(a, key, "int", toInt)
+}
+
+// GetInt64 获取value并转换为int64，支持各类整数、没有小数部分的浮点数及数字字符串
+func (a *MapStrAny) GetInt64(key string) (int64, bool, error) {
+    return getAs(a, key, "int64", toInt64)
+}
+
+// GetFloat64 获取value并转换为float64，支持各类数值及数字字符串
+func (a *MapStrAny) GetFloat64(key string) (float64, bool, error) {
+    return getAs(a, key, "float64", toFloat64)
+}
+
+// GetBool 获取value并转换为bool，支持strconv.ParseBool可解析的字符串，数值非0为true
+func (a *MapStrAny) GetBool(key string) (bool, bool, error) {
+    return getAs(a, key, "bool", toBool)
+}
+
+// GetDuration 获取value并转换为time.Duration，字符串按time.ParseDuration解析（如"30s"），整数视为纳秒
+func (a *MapStrAny) GetDuration(key string) (time.Duration, bool, error) {
+    return getAs(a, key, "time.Duration", toDuration)
+}
+
+// GetTime 获取value并转换为time.Time，字符串按RFC3339解析，整数视为Unix秒
+func (a *MapStrAny) GetTime(key string) (time.Time, bool, error) {
+    return getAs(a, key, "time.Time", toTime)
+}
+
+// GetSlice 获取value并转换为[]interface{}，支持任意切片和数组
+func (a *MapStrAny) GetSlice(key string) ([]interface{}, bool, error) {
+    return getAs(a, key, "[]interface{}", toSlice)
+}
+
+// MustGetString 同GetString，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetString(key string) string {
+    return mustAs(a.GetString(key))(key)
+}
+
+// MustGetInt 同GetInt，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetInt(key string) int {
+    return mustAs(a.GetInt(key))(key)
+}
+
+// MustGetInt64 同GetInt64，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetInt64(key string) int64 {
+    return mustAs(a.GetInt64(key))(key)
+}
+
+// MustGetFloat64 同GetFloat64，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetFloat64(key string) float64 {
+    return mustAs(a.GetFloat64(key))(key)
+}
+
+// MustGetBool 同GetBool，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetBool(key string) bool {
+    return mustAs(a.GetBool(key))(key)
+}
+
+// MustGetDuration 同GetDuration，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetDuration(key string) time.Duration {
+    return mustAs(a.GetDuration(key))(key)
+}
+
+// MustGetTime 同GetTime，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetTime(key string) time.Time {
+    return mustAs(a.GetTime(key))(key)
+}
+
+// MustGetSlice 同GetSlice，key不存在或转换失败时panic
+func (a *MapStrAny) MustGetSlice(key string) []interface{} {
+    return mustAs(a.GetSlice(key))(key)
+}
+
+// GetStringOr 同GetString，key不存在或转换失败时返回def
+func (a *MapStrAny) GetStringOr(key string, def string) string {
+    return orAs(def)(a.GetString(key))
+}
+
+// GetIntOr 同GetInt，key不存在或转换失败时返回def
+func (a *MapStrAny) GetIntOr(key string, def int) int {
+    return orAs(def)(a.GetInt(key))
+}
+
+// GetInt64Or 同GetInt64，key不存在或转换失败时返回def
+func (a *MapStrAny) GetInt64Or(key string, def int64) int64 {
+    return orAs(def)(a.GetInt64(key))
+}
+
+// GetFloat64Or 同GetFloat64，key不存在或转换失败时返回def
+func (a *MapStrAny) GetFloat64Or(key string, def float64) float64 {
+    return orAs(def)(a.GetFloat64(key))
+}
+
+// GetBoolOr 同GetBool，key不存在或转换失败时返回def
+func (a *MapStrAny) GetBoolOr(key string, def bool) bool {
+    return orAs(def)(a.GetBool(key))
+}
+
+// GetDurationOr 同GetDuration，key不存在或转换失败时返回def
+func (a *MapStrAny) GetDurationOr(key string, def time.Duration) time.Duration {
+    return orAs(def)(a.GetDuration(key))
+}
+
+// GetTimeOr 同GetTime，key不存在或转换失败时返回def
+func (a *MapStrAny) GetTimeOr(key string, def time.Time) time.Time {
+    return orAs(def)(a.GetTime(key))
+}
+
+// GetSliceOr 同GetSlice，key不存在或转换失败时返回def
+func (a *MapStrAny) GetSliceOr(key string, def []interface{}) []interface{} {
+    return orAs(def)(a.GetSlice(key))
+}
+
+// getAs 获取value并使用conv转换类型，转换失败（包括panic）时返回*ConvertError
+func getAs[T any](a *MapStrAny, key string, typ string, conv func(v interface{}) (T, error)) (val T, ok bool, err error) {
+    v, ok, err := a.Get(key)
+    if !ok || err != nil {
+        return val, ok, err
+    }
+    if val, err = convert(v, conv); err != nil {
+        return val, true, &ConvertError{Key: key, Value: v, Type: typ, Err: err}
+    }
+    return val, true, nil
+}
+
+// convert 调用conv转换类型，conv内的panic以error形式返回，例如值接收者String方法的nil指针
+func convert[T any](v interface{}, conv func(v interface{}) (T, error)) (val T, err error) {
+    defer errorRecover(&err)
+    return conv(v)
+}
+
+// mustAs 返回检查Get*结果的函数，key不存在或转换失败时panic
+func mustAs[T any](val T, ok bool, err error) func(key string) T {
+    return func(key string) T {
+        if err != nil {
+            panic(err)
+        }
+        if !ok {
+            panic(fmt.Errorf("%w: %q", ErrMissing, key))
+        }
+        return val
+    }
+}
+
+// orAs 返回检查Get*结果的函数，key不存在或转换失败时返回def
+func orAs[T any](def T) func(val T, ok bool, err error) T {
+    return func(val T, ok bool, err error) T {
+        if !ok || err != nil {
+            return def
+        }
+        return val
+    }
+}
+
+// errUnsupported 不支持转换的类型
+var errUnsupported = errors.New("unsupported type")
+
+// toString 转换为string
+func toString(v interface{}) (string, error) {
+    switch s := v.(type) {
+    case string:
+        return s, nil
+    case []byte:
+        return string(s), nil
+    case json.Number:
+        return s.String(), nil
+    case fmt.Stringer:
+        return s.String(), nil
+    }
+    switch reflect.ValueOf(v).Kind() {
+    case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+        reflect.Float32, reflect.Float64, reflect.String:
+        return fmt.Sprint(v), nil
+    }
+    return "", errUnsupported
+}
+
+// toInt64 转换为int64，浮点数必须没有小数部分且不溢出
+func toInt64(v interface{}) (int64, error) {
+    switch s := v.(type) {
+    case string:
+        return strconv.ParseInt(s, 10, 64)
+    case json.Number:
+        return strconv.ParseInt(s.String(), 10, 64)
+    }
+    rv := reflect.ValueOf(v)
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return rv.Int(), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        if rv.Uint() > math.MaxInt64 {
+            return 0, strconv.ErrRange
+        }
+        return int64(rv.Uint()), nil
+    case reflect.Float32, reflect.Float64:
+        f := rv.Float()
+        if f != math.Trunc(f) {
+            return 0, errors.New("has fractional part")
+        }
+        if f < math.MinInt64 || f >= math.MaxInt64 {
+            return 0, strconv.ErrRange
+        }
+        return int64(f), nil
+    case reflect.String:
+        return strconv.ParseInt(rv.String(), 10, 64)
+    }
+    return 0, errUnsupported
+}
+
+// toInt 转换为int
+func toInt(v interface{}) (int, error) {
+    i, err := toInt64(v)
+    if err != nil {
+        return 0, err
+    }
+    if i < math.MinInt || i > math.MaxInt {
+        return 0, strconv.ErrRange
+    }
+    return int(i), nil
+}
+
+// toFloat64 转换为float64
+func toFloat64(v interface{}) (float64, error) {
+    switch s := v.(type) {
+    case string:
+        return strconv.ParseFloat(s, 64)
+    case json.Number:
+        return s.Float64()
+    }
+    rv := reflect.ValueOf(v)
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(rv.Int()), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return float64(rv.Uint()), nil
+    case reflect.Float32, reflect.Float64:
+        return rv.Float(), nil
+    case reflect.String:
+        return strconv.ParseFloat(rv.String(), 64)
+    }
+    return 0, errUnsupported
+}
+
+// toBool 转换为bool
+func toBool(v interface{}) (bool, error) {
+    switch s := v.(type) {
+    case bool:
+        return s, nil
+    case string:
+        return strconv.ParseBool(s)
+    }
+    if f, err := toFloat64(v); err == nil {
+        return f != 0, nil
+    }
+    return false, errUnsupported
+}
+
+// toDuration 转换为time.Duration
+func toDuration(v interface{}) (time.Duration, error) {
+    switch s := v.(type) {
+    case time.Duration:
+        return s, nil
+    case string:
+        return time.ParseDuration(s)
+    }
+    i, err := toInt64(v)
+    return time.Duration(i), err
+}
+
+// toTime 转换为time.Time
+func toTime(v interface{}) (time.Time, error) {
+    switch s := v.(type) {
+    case time.Time:
+        return s, nil
+    case *time.Time:
+        if s == nil {
+            return time.Time{}, errUnsupported
+        }
+        return *s, nil
+    case string:
+        return time.Parse(time.RFC3339Nano, s)
+    }
+    i, err := toInt64(v)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return time.Unix(i, 0), nil
+}
+
+// toSlice 转换为[]interface{}
+func toSlice(v interface{}) ([]interface{}, error) {
+    if s, ok := v.([]interface{}); ok {
+        return s, nil
+    }
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+        return nil, errUnsupported
+    }
+    s := make([]interface{}, rv.Len())
+    for i := range s {
+        s[i] = rv.Index(i).Interface()
+    }
+    return s, nil
+}
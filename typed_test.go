@@ -0,0 +1,162 @@
+package smap
+
+import (
+    "encoding/json"
+    "errors"
+    "reflect"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func getTypedMap() *MapStrAny {
+    return getMapStrAny(map[string]interface{}{
+        "int":      30,
+        "uint8":    uint8(7),
+        "float":    2.5,
+        "whole":    float64(4),
+        "str":      "42",
+        "strf":     "1.25",
+        "bool":     true,
+        "strbool":  "false",
+        "dur":      "30s",
+        "duration": time.Minute,
+        "time":     "2024-01-02T03:04:05Z",
+        "unix":     int64(1700000000),
+        "number":   json.Number("12"),
+        "slice":    []string{"a", "b"},
+        "array":    [2]int{1, 2},
+        "any":      []interface{}{1, "x"},
+        "map":      map[string]interface{}{},
+        "nil":      nil,
+    }, true)
+}
+
+func TestMapStrAny_GetTyped(t *testing.T) {
+    m := getTypedMap()
+    tests := []struct {
+        name    string
+        get     func() (interface{}, bool, error)
+        want    interface{}
+        wantOk  bool
+        wantErr bool
+    }{
+        {name: "string", get: wrap(m.GetString, "str"), want: "42", wantOk: true},
+        {name: "string from int", get: wrap(m.GetString, "int"), want: "30", wantOk: true},
+        {name: "string from duration", get: wrap(m.GetString, "duration"), want: "1m0s", wantOk: true},
+        {name: "string from map", get: wrap(m.GetString, "map"), want: "", wantOk: true, wantErr: true},
+        {name: "string missing", get: wrap(m.GetString, "missing"), want: "", wantOk: false},
+        {name: "int", get: wrap(m.GetInt, "int"), want: 30, wantOk: true},
+        {name: "int from uint8", get: wrap(m.GetInt, "uint8"), want: 7, wantOk: true},
+        {name: "int from whole float", get: wrap(m.GetInt, "whole"), want: 4, wantOk: true},
+        {name: "int from fraction", get: wrap(m.GetInt, "float"), want: 0, wantOk: true, wantErr: true},
+        {name: "int from string", get: wrap(m.GetInt, "str"), want: 42, wantOk: true},
+        {name: "int from nil", get: wrap(m.GetInt, "nil"), want: 0, wantOk: true, wantErr: true},
+        {name: "int64 from int", get: wrap(m.GetInt64, "int"), want: int64(30), wantOk: true},
+        {name: "int64 from json number", get: wrap(m.GetInt64, "number"), want: int64(12), wantOk: true},
+        {name: "float64 from int", get: wrap(m.GetFloat64, "int"), want: float64(30), wantOk: true},
+        {name: "float64 from string", get: wrap(m.GetFloat64, "strf"), want: 1.25, wantOk: true},
+        {name: "float64 from bool", get: wrap(m.GetFloat64, "bool"), want: float64(0), wantOk: true, wantErr: true},
+        {name: "bool", get: wrap(m.GetBool, "bool"), want: true, wantOk: true},
+        {name: "bool from string", get: wrap(m.GetBool, "strbool"), want: false, wantOk: true},
+        {name: "bool from int", get: wrap(m.GetBool, "int"), want: true, wantOk: true},
+        {name: "bool from bad string", get: wrap(m.GetBool, "dur"), want: false, wantOk: true, wantErr: true},
+        {name: "duration from string", get: wrap(m.GetDuration, "dur"), want: 30 * time.Second, wantOk: true},
+        {name: "duration", get: wrap(m.GetDuration, "duration"), want: time.Minute, wantOk: true},
+        {name: "duration from int", get: wrap(m.GetDuration, "int"), want: time.Duration(30), wantOk: true},
+        {name: "time from string", get: wrap(m.GetTime, "time"), want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), wantOk: true},
+        {name: "time from unix", get: wrap(m.GetTime, "unix"), want: time.Unix(1700000000, 0), wantOk: true},
+        {name: "time from bad string", get: wrap(m.GetTime, "str"), want: time.Time{}, wantOk: true, wantErr: true},
+        {name: "slice", get: wrap(m.GetSlice, "any"), want: []interface{}{1, "x"}, wantOk: true},
+        {name: "slice from typed slice", get: wrap(m.GetSlice, "slice"), want: []interface{}{"a", "b"}, wantOk: true},
+        {name: "slice from array", get: wrap(m.GetSlice, "array"), want: []interface{}{1, 2}, wantOk: true},
+        {name: "slice from string", get: wrap(m.GetSlice, "str"), want: []interface{}(nil), wantOk: true, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, ok, err := tt.get()
+            if (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk {
+                t.Errorf("got = %#v, %v, want %#v, %v", got, ok, tt.want, tt.wantOk)
+            }
+        })
+    }
+}
+
+// wrap 把类型化的Get方法转换为返回interface{}的函数
+func wrap[T any](get func(key string) (T, bool, error), key string) func() (interface{}, bool, error) {
+    return func() (interface{}, bool, error) {
+        val, ok, err := get(key)
+        return val, ok, err
+    }
+}
+
+func TestMapStrAny_ConvertError(t *testing.T) {
+    m := getTypedMap()
+    _, _, err := m.GetInt("dur")
+    var convErr *ConvertError
+    if !errors.As(err, &convErr) || convErr.Key != "dur" || convErr.Type != "int" {
+        t.Fatalf("GetInt() error = %#v, want *ConvertError", err)
+    }
+    if !errors.Is(err, strconv.ErrSyntax) {
+        t.Errorf("GetInt() error = %v, want wrapping strconv.ErrSyntax", err)
+    }
+    if _, _, err := m.GetInt64("nil"); err == nil || err.Error() != `smap: key "nil": cannot convert <nil>(<nil>) to int64: unsupported type` {
+        t.Errorf("GetInt64() error = %v", err)
+    }
+    // 值接收者的String方法在nil指针上调用会panic
+    m.Set("nilStringer", (*time.Duration)(nil))
+    if _, _, err := m.GetString("nilStringer"); !errors.As(err, &convErr) || convErr.Key != "nilStringer" {
+        t.Errorf("GetString() nil Stringer error = %v, want *ConvertError", err)
+    }
+}
+
+func TestMapStrAny_MustGet(t *testing.T) {
+    m := getTypedMap()
+    if got := m.MustGetInt("str"); got != 42 {
+        t.Errorf("MustGetInt() = %v, want 42", got)
+    }
+    if got := m.MustGetDuration("dur"); got != 30*time.Second {
+        t.Errorf("MustGetDuration() = %v, want 30s", got)
+    }
+    tests := []struct {
+        name string
+        fn   func()
+        want error
+    }{
+        {name: "missing", fn: func() { m.MustGetString("missing") }, want: ErrMissing},
+        {name: "convert", fn: func() { m.MustGetBool("map") }, want: errUnsupported},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            defer func() {
+                err, _ := recover().(error)
+                if !errors.Is(err, tt.want) {
+                    t.Errorf("recover() = %v, want %v", err, tt.want)
+                }
+            }()
+            tt.fn()
+        })
+    }
+}
+
+func TestMapStrAny_GetOr(t *testing.T) {
+    m := getTypedMap()
+    if got := m.GetIntOr("missing", 5); got != 5 {
+        t.Errorf("GetIntOr() missing = %v, want 5", got)
+    }
+    if got := m.GetIntOr("float", 5); got != 5 {
+        t.Errorf("GetIntOr() invalid = %v, want 5", got)
+    }
+    if got := m.GetFloat64Or("float", 1); got != 2.5 {
+        t.Errorf("GetFloat64Or() = %v, want 2.5", got)
+    }
+    if got := m.GetStringOr("missing", "x"); got != "x" {
+        t.Errorf("GetStringOr() = %v, want x", got)
+    }
+    if got := m.GetDurationOr("missing", time.Hour); got != time.Hour {
+        t.Errorf("GetDurationOr() = %v, want 1h", got)
+    }
+}
@@ -0,0 +1,90 @@
+package smap
+
+import (
+    "errors"
+    "fmt"
+)
+
+// ErrConflict 版本号不匹配，可用errors.Is判断
+var ErrConflict = errors.New("smap: version conflict")
+
+// ConflictError 版本冲突错误，Actual为0表示key不存在
+type ConflictError[K comparable] struct {
+    Key      K
+    Expected uint64
+    Actual   uint64
+}
+
+// Error 错误信息
+func (e *ConflictError[K]) Error() string {
+    return fmt.Sprintf("smap: version conflict on key %v: expected %d, actual %d", e.Key, e.Expected, e.Actual)
+}
+
+// Is 支持errors.Is(err, ErrConflict)
+func (e *ConflictError[K]) Is(target error) bool {
+    return target == ErrConflict
+}
+
+// GetVersioned 获取value及其版本号，版本号在每次写入时单调递增
+func (a *Map[K, V]) GetVersioned(key K) (val V, version uint64, ok bool, err error) {
+    defer errorRecover(&err)
+    if e, ok := a.fetch(key); ok {
+        return e.value, e.version, true, nil
+    }
+
+    return val, 0, false, err
+}
+
+// SetIfVersion 当前版本号等于version时写入value并返回新的版本号
+// version为0表示要求key不存在，版本号不匹配时返回*ConflictError
+func (a *Map[K, V]) SetIfVersion(key K, value V, version uint64) (newVersion uint64, err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if err := a.checkVersion(s, key, version); err != nil {
+        return 0, err
+    }
+    e := a.newEntry(value)
+    e.version = a.version.Add(1)
+    if err := a.storeLocked(s, key, e); err != nil {
+        return 0, err
+    }
+
+    return e.version, err
+}
+
+// RemoveIfVersion 当前版本号等于version时删除key，版本号不匹配时返回*ConflictError
+func (a *Map[K, V]) RemoveIfVersion(key K, version uint64) (err error) {
+    defer errorRecover(&err)
+    s := a.shard(key)
+    s.lock()
+    defer a.unlock(s)
+    if err := a.checkVersion(s, key, version); err != nil {
+        return err
+    }
+
+    return a.removeLocked(s, key)
+}
+
+// checkVersion 检查key的当前版本号，需在持有分片写锁时调用
+func (a *Map[K, V]) checkVersion(s *shard[K, V], key K, version uint64) error {
+    var actual uint64
+    if e, ok := lookup(s.current(), key, a.now()); ok {
+        actual = e.version
+    }
+    if actual != version {
+        return &ConflictError[K]{Key: key, Expected: version, Actual: actual}
+    }
+    return nil
+}
+
+// raiseVersion 把版本号计数器推进到至少version，用于恢复数据后保证之后分配的版本号仍然递增
+func (a *Map[K, V]) raiseVersion(version uint64) {
+    for {
+        cur := a.version.Load()
+        if cur >= version || a.version.CompareAndSwap(cur, version) {
+            return
+        }
+    }
+}
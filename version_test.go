@@ -0,0 +1,100 @@
+package smap
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestMap_GetVersioned(t *testing.T) {
+    m := NewMapStrAny(true)
+    if _, version, ok, err := m.GetVersioned("a"); ok || version != 0 || err != nil {
+        t.Errorf("GetVersioned() missing = %v, %v, %v", version, ok, err)
+    }
+    m.Set("a", 1)
+    _, v1, _, _ := m.GetVersioned("a")
+    m.Set("a", 2)
+    val, v2, ok, err := m.GetVersioned("a")
+    if !ok || err != nil || val != 2 || v2 <= v1 {
+        t.Errorf("GetVersioned() = %v, %v, %v, %v, want version > %d", val, v2, ok, err, v1)
+    }
+    m.Remove("a")
+    m.Set("a", 3)
+    if _, v3, _, _ := m.GetVersioned("a"); v3 <= v2 {
+        t.Errorf("GetVersioned() after recreate = %d, want > %d", v3, v2)
+    }
+}
+
+func TestMap_SetIfVersion(t *testing.T) {
+    tests := []struct {
+        name     string
+        exists   bool
+        stale    bool
+        wantErr  bool
+        wantData int
+    }{
+        {name: "create", exists: false, wantData: 2},
+        {name: "create conflict", exists: true, stale: true, wantErr: true, wantData: 1},
+        {name: "update", exists: true, wantData: 2},
+        {name: "update conflict", exists: false, stale: true, wantErr: true},
+    }
+    for _, opts := range [][]Option{nil, {WithShards(4)}, {WithCopyOnWrite()}} {
+        for _, tt := range tests {
+            t.Run(tt.name, func(t *testing.T) {
+                m := NewMapWith[string, int](opts...)
+                var version uint64
+                if tt.exists {
+                    m.Set("a", 1)
+                    _, version, _, _ = m.GetVersioned("a")
+                }
+                if tt.stale {
+                    version = 100
+                    if tt.exists {
+                        version = 0
+                    }
+                }
+                newVersion, err := m.SetIfVersion("a", 2, version)
+                if (err != nil) != tt.wantErr {
+                    t.Fatalf("SetIfVersion() error = %v, wantErr %v", err, tt.wantErr)
+                }
+                if err != nil {
+                    var conflict *ConflictError[string]
+                    if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) || conflict.Expected != version {
+                        t.Errorf("SetIfVersion() error = %#v, want ConflictError", err)
+                    }
+                } else if _, got, _, _ := m.GetVersioned("a"); got != newVersion {
+                    t.Errorf("SetIfVersion() version = %d, want %d", newVersion, got)
+                }
+                if got, _, _ := m.Get("a"); got != tt.wantData {
+                    t.Errorf("SetIfVersion() data = %v, want %v", got, tt.wantData)
+                }
+            })
+        }
+    }
+}
+
+func TestMap_RemoveIfVersion(t *testing.T) {
+    m := NewMapAny(true)
+    m.Set("a", 1)
+    _, version, _, _ := m.GetVersioned("a")
+    m.Set("a", 2)
+    if err := m.RemoveIfVersion("a", version); !errors.Is(err, ErrConflict) {
+        t.Errorf("RemoveIfVersion() stale error = %v, want conflict", err)
+    }
+    _, version, _, _ = m.GetVersioned("a")
+    if err := m.RemoveIfVersion("a", version); err != nil || m.Has("a") {
+        t.Errorf("RemoveIfVersion() error = %v, has %v", err, m.Has("a"))
+    }
+}
+
+func TestMap_VersionTxnRollback(t *testing.T) {
+    m := NewMap[string, int]()
+    m.Set("a", 1)
+    _, version, _, _ := m.GetVersioned("a")
+    m.Txn(func(tx *Tx[string, int]) error {
+        tx.Set("a", 2)
+        return errors.New("abort")
+    })
+    if _, got, _, _ := m.GetVersioned("a"); got != version {
+        t.Errorf("GetVersioned() after rollback = %d, want %d", got, version)
+    }
+}
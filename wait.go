@@ -0,0 +1,49 @@
+package smap
+
+import (
+    "context"
+)
+
+// WaitFor 阻塞等待key出现并返回value，ctx被取消或超时时返回ctx.Err()，等待期间map被关闭时返回ErrClosed
+func (a *Map[K, V]) WaitFor(ctx context.Context, key K) (val V, err error) {
+    return a.WaitUntil(ctx, key, nil)
+}
+
+// WaitUntil 阻塞等待key存在且value满足pred并返回value，pred为nil时只等待key出现
+// 等待期间通过变更通知唤醒，不会轮询，pred在持有锁之外调用
+func (a *Map[K, V]) WaitUntil(ctx context.Context, key K, pred func(val V) bool) (val V, err error) {
+    defer errorRecover(&err)
+    sub, e, ok := a.waiter(key)
+    defer a.Unsubscribe(sub.id)
+    for {
+        if ok && (pred == nil || pred(e.value)) {
+            return e.value, nil
+        }
+        select {
+        case ev, open := <-sub.ch:
+            if !open {
+                return val, ErrClosed
+            }
+            e.value, ok = ev.New, ev.Op == OpSet
+        case <-ctx.Done():
+            return val, ctx.Err()
+        }
+    }
+}
+
+// waiter 在分片写锁下读取key的当前值并订阅key的变更，保证两者之间的修改不会被遗漏
+// 订阅只保留最新的一个事件，等待方通过事件获取新值而不再读取map，非并发安全模式下同样可用
+func (a *Map[K, V]) waiter(key K) (*subscription[K, V], entry[V], bool) {
+    s := a.shard(key)
+    sub := &subscription[K, V]{
+        keys:   map[K]struct{}{key: {}},
+        latest: true,
+        ch:     make(chan Event[K, V], 1),
+        done:   make(chan struct{}),
+    }
+    s.lock()
+    defer s.unlock()
+    e, ok := lookup(s.current(), key, a.now())
+    a.subscribe(sub)
+    return sub, e, ok
+}
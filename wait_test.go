@@ -0,0 +1,100 @@
+package smap
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestMap_WaitFor(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {name: "unsafe", opts: nil},
+        {name: "safe", opts: []Option{WithSafe()}},
+        {name: "sharded", opts: []Option{WithShards(4)}},
+        {name: "cow", opts: []Option{WithCopyOnWrite()}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapWith[string, int](tt.opts...)
+            m.Set("a", 1)
+            if val, err := m.WaitFor(context.Background(), "a"); err != nil || val != 1 {
+                t.Errorf("WaitFor() existing = %v, %v, want 1", val, err)
+            }
+            done := make(chan struct{})
+            go func() {
+                defer close(done)
+                if val, err := m.WaitFor(context.Background(), "b"); err != nil || val != 2 {
+                    t.Errorf("WaitFor() = %v, %v, want 2", val, err)
+                }
+            }()
+            // 等待订阅完成，非并发安全模式下保证读写有序
+            for !m.watching() {
+                time.Sleep(time.Millisecond)
+            }
+            m.Set("b", 2)
+            <-done
+        })
+    }
+}
+
+func TestMap_WaitUntil(t *testing.T) {
+    m := NewMapStrAny(true)
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        val, err := m.WaitUntil(context.Background(), "count", func(val interface{}) bool {
+            return val.(int) >= 3
+        })
+        if err != nil || val != 3 {
+            t.Errorf("WaitUntil() = %v, %v, want 3", val, err)
+        }
+    }()
+    for i := 0; i <= 3; i++ {
+        time.Sleep(time.Millisecond)
+        m.Set("count", i)
+    }
+    <-done
+    if size := len(*m.watchers.subs.Load()); size != 0 {
+        t.Errorf("WaitUntil() left %d subscriptions", size)
+    }
+}
+
+func TestMap_WaitForClose(t *testing.T) {
+    m := NewMap[string, int](true)
+    done := make(chan error, 1)
+    go func() {
+        _, err := m.WaitFor(context.Background(), "a")
+        done <- err
+    }()
+    for m.watchers.subs.Load() == nil || len(*m.watchers.subs.Load()) == 0 {
+        time.Sleep(time.Millisecond)
+    }
+    m.Close()
+    select {
+    case err := <-done:
+        if !errors.Is(err, ErrClosed) {
+            t.Errorf("WaitFor() after Close error = %v, want %v", err, ErrClosed)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("WaitFor() still blocked after Close")
+    }
+}
+
+func TestMap_WaitForCancel(t *testing.T) {
+    m := NewMap[string, int](true)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, err := m.WaitFor(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("WaitFor() error = %v, want %v", err, context.DeadlineExceeded)
+    }
+    m.Set("a", 1)
+
+    mAny := NewMapAny(true)
+    if _, err := mAny.WaitFor(context.Background(), []int{1}); err == nil {
+        t.Errorf("WaitFor() unhashable key error = nil")
+    }
+}
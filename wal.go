@@ -0,0 +1,626 @@
+package smap
+
+import (
+    "bufio"
+    "bytes"
+    "cmp"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// SyncPolicy 预写日志的同步策略，决定写入的日志何时通过fsync落盘
+type SyncPolicy int
+
+const (
+    // SyncAlways 每次写操作都同步日志，写操作返回时数据已落盘
+    SyncAlways SyncPolicy = iota + 1
+    // SyncInterval 由后台协程定期同步日志，崩溃时可能丢失最近一个同步间隔内的修改
+    SyncInterval
+    // SyncNever 只在压缩和关闭时同步日志，其余时候交由操作系统决定何时落盘
+    SyncNever
+)
+
+// walMagic 日志文件的文件头标识，walVersion 当前的日志格式版本
+var walMagic = [4]byte{'S', 'M', 'W', 'L'}
+
+const walVersion = 1
+
+// 日志记录的操作类型
+const (
+    walSet byte = iota + 1
+    walRemove
+    // walBatch 批量记录，Batch中的记录作为一个整体写入和重放
+    walBatch
+    // walCounter 压缩时记录的版本号计数器，保证已删除的key的版本号不会在重新打开后被再次分配
+    walCounter
+)
+
+// walRecord 日志中的一条记录，Expire为过期时间(UnixNano)，0表示永不过期，Version为数据项的版本号
+type walRecord[K comparable, V any] struct {
+    Op      byte
+    Key     K
+    Value   V
+    Expire  int64
+    Version uint64
+    Batch   []walRecord[K, V]
+}
+
+// walLog 预写日志，目录中包含若干日志文件和最多一个有效的快照文件
+// 序号为n的快照包含序号小于n的日志文件中的所有修改，恢复时加载快照后按顺序重放序号不小于n的日志文件
+type walLog[K comparable, V any] struct {
+    dir      string
+    policy   SyncPolicy
+    interval time.Duration
+    limit    int64
+    // mutex 保护当前日志文件及编码状态，同一个日志文件中的记录共用一个gob编码流
+    mutex   sync.Mutex
+    file    *os.File
+    seq     uint64
+    enc     *gob.Encoder
+    payload bytes.Buffer
+    frame   []byte
+    size    int64
+    dirty   bool
+    err     error
+    // batching 为true时记录缓存在batch中，由commit作为一条批量记录写入
+    batching bool
+    batch    []walRecord[K, V]
+    // compacting 保证同一时刻只有一次压缩，kick 通知后台协程压缩
+    compacting sync.Mutex
+    kick       chan struct{}
+}
+
+// OpenMap 打开dir中保存的map，dir不存在时创建，之后的每次写操作都先写入预写日志
+// 打开时加载最近的快照并重放之后的日志，日志末尾不完整或校验失败的记录会被截断
+// 日志大小达到WithCompactSize时后台把日志压缩为新的快照，也可以调用Compact手动压缩
+// 通过OpenMap打开的map总是并发安全的，不再使用时需调用Close同步并关闭日志，同一目录同时只能被一个map打开
+// 从加载函数或持久化存储加载的数据以及被淘汰的数据不会写入日志，数据的成本不会被保存
+func OpenMap[K comparable, V any](dir string, opts ...Option) (*Map[K, V], error) {
+    o := newOptions(append(opts, WithSafe())...)
+    w, err := openWAL[K, V](dir, o)
+    if err != nil {
+        return nil, err
+    }
+    m := newMapWith[K, V](o)
+    if m.closing == nil {
+        m.closing = make(chan struct{})
+    }
+    seq, err := w.recover(m.replay)
+    if err == nil {
+        err = w.create(seq)
+    }
+    if err != nil {
+        m.Close()
+        return nil, err
+    }
+    m.wal = w
+    m.wg.Add(1)
+    go m.walWorker()
+    return m, nil
+}
+
+// OpenMapAny 打开dir中保存的MapAny，interface{}中的自定义类型需先调用RegisterType注册
+func OpenMapAny(dir string, opts ...Option) (*MapAny, error) {
+    return OpenMap[interface{}, interface{}](dir, opts...)
+}
+
+// OpenMapStrAny 打开dir中保存的MapStrAny，interface{}中的自定义类型需先调用RegisterType注册
+func OpenMapStrAny(dir string, opts ...Option) (*MapStrAny, error) {
+    m, err := OpenMap[string, interface{}](dir, opts...)
+    if err != nil {
+        return nil, err
+    }
+    return &MapStrAny{strAnyMap: m, sep: newOptions(opts...).pathSep}, nil
+}
+
+// openWAL 按配置创建预写日志，dir不存在时创建
+func openWAL[K comparable, V any](dir string, o *options) (*walLog[K, V], error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+    w := &walLog[K, V]{
+        dir:      dir,
+        policy:   o.syncPolicy,
+        interval: o.syncInterval,
+        limit:    o.compactSize,
+        kick:     make(chan struct{}, 1),
+    }
+    if w.policy == 0 {
+        w.policy = SyncInterval
+    }
+    if w.interval <= 0 {
+        w.interval = time.Second
+    }
+    if w.limit == 0 {
+        w.limit = 64 << 20
+    }
+    return w, nil
+}
+
+// replay 把快照或日志中的一条记录写入内存，已过期的数据直接删除
+// 恢复数据项原有的版本号，并把版本号计数器推进到出现过的最大版本号
+func (a *Map[K, V]) replay(rec walRecord[K, V]) (err error) {
+    a.raiseVersion(rec.Version)
+    if rec.Op == walCounter {
+        return nil
+    }
+    if rec.Op == walBatch {
+        for _, r := range rec.Batch {
+            if err := a.replay(r); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    defer errorRecover(&err)
+    s := a.shard(rec.Key)
+    s.lock()
+    defer a.unlock(s)
+    if rec.Op == walRemove || (rec.Expire > 0 && rec.Expire <= time.Now().UnixNano()) {
+        a.dropLocked(s, rec.Key)
+        return nil
+    }
+    if rec.Expire > 0 {
+        a.expiring.Store(true)
+    }
+    err = a.cacheLocked(s, rec.Key, entry[V]{value: rec.Value, expire: rec.Expire, cost: 1, version: rec.Version})
+    if errors.Is(err, ErrRejected) {
+        return nil
+    }
+    return err
+}
+
+// walFile 目录中的一个日志或快照文件
+type walFile struct {
+    name string
+    seq  uint64
+}
+
+// walName 日志文件和快照文件的文件名
+func walName(prefix string, seq uint64, ext string) string {
+    return fmt.Sprintf("%s-%020d%s", prefix, seq, ext)
+}
+
+// list 按序号从小到大列出目录中的日志文件和快照文件，同时删除压缩中断时留下的临时文件
+func (w *walLog[K, V]) list() (logs []walFile, snaps []walFile, err error) {
+    entries, err := os.ReadDir(w.dir)
+    if err != nil {
+        return nil, nil, err
+    }
+    for _, de := range entries {
+        name := de.Name()
+        if strings.HasSuffix(name, ".tmp") {
+            if err := os.Remove(filepath.Join(w.dir, name)); err != nil {
+                return nil, nil, err
+            }
+            continue
+        }
+        if seq, ok := parseWALName(name, "wal", ".log"); ok {
+            logs = append(logs, walFile{name: name, seq: seq})
+        } else if seq, ok := parseWALName(name, "snapshot", ".smap"); ok {
+            snaps = append(snaps, walFile{name: name, seq: seq})
+        }
+    }
+    byseq := func(a, b walFile) int {
+        return cmp.Compare(a.seq, b.seq)
+    }
+    slices.SortFunc(logs, byseq)
+    slices.SortFunc(snaps, byseq)
+    return logs, snaps, nil
+}
+
+// parseWALName 解析walName生成的文件名中的序号
+func parseWALName(name, prefix, ext string) (uint64, bool) {
+    if !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, ext) {
+        return 0, false
+    }
+    seq, err := strconv.ParseUint(name[len(prefix)+1:len(name)-len(ext)], 10, 64)
+    return seq, err == nil
+}
+
+// recover 加载最近的快照并按顺序重放之后的日志，返回新日志文件应使用的序号
+// 只有最后一个日志文件允许末尾的记录不完整或校验失败，这些记录会被截断
+func (w *walLog[K, V]) recover(fn func(rec walRecord[K, V]) error) (uint64, error) {
+    logs, snaps, err := w.list()
+    if err != nil {
+        return 0, err
+    }
+    base, next := uint64(0), uint64(1)
+    if len(snaps) > 0 {
+        snap := snaps[len(snaps)-1]
+        if err := w.loadSnapshot(snap.name, fn); err != nil {
+            return 0, err
+        }
+        base, next = snap.seq, snap.seq
+    }
+    for i, lf := range logs {
+        if lf.seq < base {
+            continue
+        }
+        if err := w.replayLog(lf.name, i == len(logs)-1, fn); err != nil {
+            return 0, err
+        }
+        next = lf.seq + 1
+    }
+    // 压缩在删除旧文件前中断时，旧文件的内容已包含在最新的快照中
+    return next, w.removeBefore(base)
+}
+
+// loadSnapshot 读取快照文件中的所有数据项
+func (w *walLog[K, V]) loadSnapshot(name string, fn func(rec walRecord[K, V]) error) error {
+    f, err := os.Open(filepath.Join(w.dir, name))
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, err = readBinary(f, func(e binaryEntry[K, V]) error {
+        return fn(walRecord[K, V]{Op: walSet, Key: e.Key, Value: e.Value, Expire: e.Expire, Version: e.Version})
+    })
+    if err != nil {
+        return fmt.Errorf("%w (%s)", err, name)
+    }
+    return nil
+}
+
+// replayLog 重放一个日志文件，last为true时把末尾损坏的记录截断
+func (w *walLog[K, V]) replayLog(name string, last bool, fn func(rec walRecord[K, V]) error) error {
+    path := filepath.Join(w.dir, name)
+    f, err := os.OpenFile(path, os.O_RDWR, 0)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    cr := &countingReader{r: bufio.NewReader(f)}
+    good, err := w.readLog(cr, fn)
+    if err == nil {
+        return nil
+    }
+    if !last || !errors.Is(err, ErrCorrupted) {
+        return fmt.Errorf("%w (%s)", err, name)
+    }
+    if err := f.Truncate(good); err != nil {
+        return err
+    }
+    return f.Sync()
+}
+
+// readLog 读取日志文件中的记录，返回最后一条完整记录结束的位置
+func (w *walLog[K, V]) readLog(cr *countingReader, fn func(rec walRecord[K, V]) error) (good int64, err error) {
+    header := make([]byte, 5)
+    if _, err := io.ReadFull(cr, header); err != nil {
+        if err == io.EOF {
+            // 截断后的空文件
+            return 0, nil
+        }
+        return 0, corrupted(err)
+    }
+    if !bytes.Equal(header[:4], walMagic[:]) {
+        return 0, fmt.Errorf("%w: bad magic %q", ErrCorrupted, header[:4])
+    }
+    if header[4] != walVersion {
+        return 0, fmt.Errorf("smap: unsupported log version %d", header[4])
+    }
+
+    var payload bytes.Buffer
+    dec := gob.NewDecoder(&payload)
+    for {
+        good = cr.n
+        if err := readFrame(cr, &payload); err != nil {
+            if err == io.EOF {
+                return good, nil
+            }
+            return good, err
+        }
+        var rec walRecord[K, V]
+        if err := dec.Decode(&rec); err != nil {
+            return good, err
+        }
+        if err := fn(rec); err != nil {
+            return good, err
+        }
+    }
+}
+
+// create 创建序号为seq的日志文件并作为当前日志文件，需在持有mutex或尚未开始写入时调用
+func (w *walLog[K, V]) create(seq uint64) error {
+    path := filepath.Join(w.dir, walName("wal", seq, ".log"))
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil {
+        return err
+    }
+    header := append(walMagic[:], walVersion)
+    if _, err := f.Write(header); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return err
+    }
+    if err := syncDir(w.dir); err != nil {
+        f.Close()
+        return err
+    }
+    w.file, w.seq, w.size, w.dirty = f, seq, 0, false
+    w.payload.Reset()
+    w.enc = gob.NewEncoder(&w.payload)
+    return nil
+}
+
+// rotate 同步并关闭当前日志文件，之后的记录写入序号加1的新日志文件，需在持有mutex时调用
+// 创建新日志文件失败后之后的写入都会返回该错误
+func (w *walLog[K, V]) rotate() error {
+    if w.file == nil {
+        return ErrClosed
+    }
+    if err := w.file.Sync(); err != nil {
+        return err
+    }
+    if err := w.file.Close(); err != nil {
+        return err
+    }
+    w.file = nil
+    if err := w.create(w.seq + 1); err != nil {
+        w.err = err
+        return err
+    }
+    return nil
+}
+
+// append 写入记录，SyncAlways策略下同步后才返回
+// 多条记录合并为一条批量记录写入，重放时要么全部生效要么全部不生效；事务提交期间记录先缓存，由commit一起写入
+// 写入文件失败后日志可能不完整，之后的写入都会返回该错误，需重新打开map才能恢复
+func (w *walLog[K, V]) append(recs ...walRecord[K, V]) error {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    if w.err != nil {
+        return w.err
+    }
+    if w.file == nil {
+        return ErrClosed
+    }
+    if w.batching {
+        w.batch = append(w.batch, recs...)
+        return nil
+    }
+    return w.write(recs)
+}
+
+// begin 开始缓存记录，需在持有所有分片写锁时调用，保证期间没有其他写入
+func (w *walLog[K, V]) begin() {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    w.batching, w.batch = true, nil
+}
+
+// commit 结束缓存并把缓存的记录作为一条批量记录写入
+func (w *walLog[K, V]) commit() error {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    recs := w.batch
+    w.batching, w.batch = false, nil
+    if w.err != nil {
+        return w.err
+    }
+    if w.file == nil {
+        return ErrClosed
+    }
+    return w.write(recs)
+}
+
+// abort 结束缓存并丢弃缓存的记录
+func (w *walLog[K, V]) abort() {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    w.batching, w.batch = false, nil
+}
+
+// write 把记录编码为一帧写入当前日志文件，需在持有mutex时调用
+func (w *walLog[K, V]) write(recs []walRecord[K, V]) error {
+    var rec walRecord[K, V]
+    switch len(recs) {
+    case 0:
+        return nil
+    case 1:
+        rec = recs[0]
+    default:
+        rec = walRecord[K, V]{Op: walBatch, Batch: recs}
+    }
+    w.payload.Reset()
+    if err := w.enc.Encode(rec); err != nil {
+        // 编码失败时gob流中可能已记录了未写入的类型信息，切换到新的日志文件重新开始编码流
+        w.rotate()
+        return err
+    }
+    var err error
+    if w.frame, err = writeFrame(w.file, w.frame, w.payload.Bytes()); err != nil {
+        w.err = err
+        return err
+    }
+    w.size += int64(len(w.frame))
+    if w.policy == SyncAlways {
+        if err := w.file.Sync(); err != nil {
+            w.err = err
+            return err
+        }
+    } else {
+        w.dirty = true
+    }
+    if w.limit > 0 && w.size >= w.limit {
+        select {
+        case w.kick <- struct{}{}:
+        default:
+        }
+    }
+    return nil
+}
+
+// remove 为data中存在的key写入删除记录，多个key合并为一条批量记录
+func (w *walLog[K, V]) remove(data layer[K, V], keys ...K) error {
+    recs := make([]walRecord[K, V], 0, len(keys))
+    for _, key := range keys {
+        if _, ok := data.get(key); ok {
+            recs = append(recs, walRecord[K, V]{Op: walRemove, Key: key})
+        }
+    }
+    return w.append(recs...)
+}
+
+// mark 写入版本号计数器并同步，需在持有mutex时调用
+func (w *walLog[K, V]) mark(version uint64) error {
+    if err := w.write([]walRecord[K, V]{{Op: walCounter, Version: version}}); err != nil {
+        return err
+    }
+    if err := w.file.Sync(); err != nil {
+        w.err = err
+        return err
+    }
+    w.dirty = false
+    return nil
+}
+
+// sync 同步尚未落盘的记录
+func (w *walLog[K, V]) sync() error {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    if !w.dirty || w.file == nil {
+        return nil
+    }
+    if err := w.file.Sync(); err != nil {
+        w.err = err
+        return err
+    }
+    w.dirty = false
+    return nil
+}
+
+// close 同步并关闭日志，之后的写入都会返回ErrClosed
+func (w *walLog[K, V]) close() error {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    if w.file == nil {
+        return nil
+    }
+    err := w.file.Sync()
+    err = errors.Join(err, w.file.Close())
+    w.file = nil
+    return err
+}
+
+// writeSnapshot 把快照写入序号为seq的快照文件，先写入临时文件，同步后再重命名
+func (w *walLog[K, V]) writeSnapshot(snap *Snapshot[K, V], seq uint64) error {
+    path := filepath.Join(w.dir, walName("snapshot", seq, ".smap"))
+    f, err := os.Create(path + ".tmp")
+    if err != nil {
+        return err
+    }
+    _, err = snap.WriteTo(f)
+    if err == nil {
+        err = f.Sync()
+    }
+    err = errors.Join(err, f.Close())
+    if err == nil {
+        err = os.Rename(path+".tmp", path)
+    }
+    if err != nil {
+        os.Remove(path + ".tmp")
+        return err
+    }
+    return syncDir(w.dir)
+}
+
+// removeBefore 删除序号小于seq的日志文件和快照文件
+func (w *walLog[K, V]) removeBefore(seq uint64) error {
+    logs, snaps, err := w.list()
+    if err != nil {
+        return err
+    }
+    for _, f := range append(logs, snaps...) {
+        if f.seq >= seq {
+            continue
+        }
+        if err := os.Remove(filepath.Join(w.dir, f.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return err
+        }
+    }
+    return nil
+}
+
+// syncDir 同步目录，保证文件的创建、重命名和删除已落盘
+func syncDir(dir string) error {
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    err = d.Sync()
+    return errors.Join(err, d.Close())
+}
+
+// Compact 把预写日志压缩为新的快照并删除旧的日志和快照，不是通过OpenMap打开的map直接返回nil
+// 压缩时只在切换日志文件和创建快照的短暂时间内阻塞写操作，写入快照期间读写操作不受影响
+func (a *Map[K, V]) Compact() error {
+    w := a.wal
+    if w == nil {
+        return nil
+    }
+    w.compacting.Lock()
+    defer w.compacting.Unlock()
+
+    for _, s := range a.shards {
+        s.lock()
+    }
+    w.mutex.Lock()
+    err := w.rotate()
+    if err == nil {
+        // 快照只包含未删除的数据，新日志文件的第一条记录保存版本号计数器
+        err = w.mark(a.version.Load())
+    }
+    seq := w.seq
+    w.mutex.Unlock()
+    var snap *Snapshot[K, V]
+    if err == nil {
+        snap = a.snapshotLocked()
+    }
+    for i := len(a.shards) - 1; i >= 0; i-- {
+        a.shards[i].unlock()
+    }
+    if err != nil {
+        return err
+    }
+    defer snap.Release()
+
+    if err := w.writeSnapshot(snap, seq); err != nil {
+        return err
+    }
+    return w.removeBefore(seq)
+}
+
+// walWorker 预写日志的后台协程，定期同步日志，日志达到压缩大小时压缩日志
+func (a *Map[K, V]) walWorker() {
+    defer a.wg.Done()
+    var tick <-chan time.Time
+    if a.wal.policy == SyncInterval {
+        ticker := time.NewTicker(a.wal.interval)
+        defer ticker.Stop()
+        tick = ticker.C
+    }
+    for {
+        select {
+        case <-tick:
+            a.wal.sync()
+        case <-a.wal.kick:
+            a.Compact()
+        case <-a.closing:
+            return
+        }
+    }
+}
@@ -0,0 +1,455 @@
+package smap
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// walFiles 列出目录中的文件名
+func walFiles(t *testing.T, dir string) []string {
+    t.Helper()
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    names := make([]string, 0, len(entries))
+    for _, e := range entries {
+        names = append(names, e.Name())
+    }
+    return names
+}
+
+func TestOpenMap(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {name: "always", opts: []Option{WithSyncPolicy(SyncAlways)}},
+        {name: "interval", opts: []Option{WithSyncPolicy(SyncInterval), WithSyncInterval(time.Millisecond)}},
+        {name: "never", opts: []Option{WithSyncPolicy(SyncNever)}},
+        {name: "sharded", opts: []Option{WithShards(4)}},
+        {name: "cow", opts: []Option{WithCopyOnWrite()}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            m, err := OpenMapAny(dir, tt.opts...)
+            if err != nil {
+                t.Fatalf("OpenMapAny() error = %v", err)
+            }
+            m.Set("a", 1)
+            m.Set(2, []interface{}{"x", 1.5})
+            m.Set("b", 2)
+            m.Swap("a", 10)
+            m.Remove("b", "missing")
+            m.SetWithTTL("ttl", 3, time.Hour)
+            m.SetWithTTL("expired", 4, time.Nanosecond)
+            m.Txn(func(tx *Tx[interface{}, interface{}]) error {
+                tx.Set("tx", true)
+                return tx.Remove(2)
+            })
+            if err := m.Close(); err != nil {
+                t.Fatalf("Close() error = %v", err)
+            }
+            if err := m.Set("closed", 1); !errors.Is(err, ErrClosed) {
+                t.Errorf("Set() after Close error = %v, want %v", err, ErrClosed)
+            }
+
+            got, err := OpenMapAny(dir, tt.opts...)
+            if err != nil {
+                t.Fatalf("reopen error = %v", err)
+            }
+            defer got.Close()
+            want := map[interface{}]interface{}{"a": 10, "ttl": 3, "tx": true}
+            if !reflect.DeepEqual(got.All(), want) {
+                t.Errorf("All() = %v, want %v", got.All(), want)
+            }
+            if ttl, ok, _ := got.TTL("ttl"); !ok || ttl <= 0 || ttl > time.Hour {
+                t.Errorf("TTL() = %v, %v, want remaining ttl", ttl, ok)
+            }
+        })
+    }
+}
+
+func TestOpenMapStrAny(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMapStrAny(dir, WithPathSeparator('/'))
+    if err != nil {
+        t.Fatalf("OpenMapStrAny() error = %v", err)
+    }
+    if err := m.SetPath("a/b", 1); err != nil {
+        t.Fatalf("SetPath() error = %v", err)
+    }
+    m.Close()
+
+    got, err := OpenMapStrAny(dir, WithPathSeparator('/'))
+    if err != nil {
+        t.Fatalf("reopen error = %v", err)
+    }
+    defer got.Close()
+    if v, ok, err := got.GetPath("a/b"); v != 1 || !ok || err != nil {
+        t.Errorf("GetPath() = %v, %v, %v, want 1", v, ok, err)
+    }
+}
+
+func TestOpenMap_tornTail(t *testing.T) {
+    tests := []struct {
+        name string
+        tear func(data []byte) []byte
+        want map[string]int
+    }{
+        {name: "truncated", tear: func(data []byte) []byte {
+            return data[:len(data)-3]
+        }, want: map[string]int{"a": 1}},
+        {name: "checksum", tear: func(data []byte) []byte {
+            data[len(data)-1] ^= 0xff
+            return data
+        }, want: map[string]int{"a": 1}},
+        {name: "zeros", tear: func(data []byte) []byte {
+            return append(data, make([]byte, 16)...)
+        }, want: map[string]int{"a": 1, "b": 2}},
+        {name: "header", tear: func(data []byte) []byte {
+            return data[:3]
+        }, want: map[string]int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            m, err := OpenMap[string, int](dir, WithSyncPolicy(SyncNever))
+            if err != nil {
+                t.Fatal(err)
+            }
+            m.Set("a", 1)
+            m.Set("b", 2)
+            m.Close()
+            path := filepath.Join(dir, walName("wal", 1, ".log"))
+            data, err := os.ReadFile(path)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := os.WriteFile(path, tt.tear(data), 0o644); err != nil {
+                t.Fatal(err)
+            }
+
+            for i := 0; i < 2; i++ {
+                got, err := OpenMap[string, int](dir)
+                if err != nil {
+                    t.Fatalf("OpenMap() #%d error = %v", i, err)
+                }
+                if !reflect.DeepEqual(got.All(), tt.want) {
+                    t.Errorf("All() #%d = %v, want %v", i, got.All(), tt.want)
+                }
+                got.Close()
+            }
+        })
+    }
+}
+
+func TestOpenMap_corrupted(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    m.Close()
+    // 第二次打开会创建新的日志文件，第一个日志文件不再是最后一个，损坏时不能截断
+    m, err = OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("b", 2)
+    m.Close()
+    path := filepath.Join(dir, walName("wal", 1, ".log"))
+    data, _ := os.ReadFile(path)
+    data[len(data)-1] ^= 0xff
+    os.WriteFile(path, data, 0o644)
+    if _, err := OpenMap[string, int](dir); !errors.Is(err, ErrCorrupted) {
+        t.Errorf("OpenMap() error = %v, want %v", err, ErrCorrupted)
+    }
+}
+
+func TestMap_Compact(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 100; i++ {
+        m.Set("a", i)
+    }
+    m.Set("b", 1)
+    m.Remove("b")
+    if err := m.Compact(); err != nil {
+        t.Fatalf("Compact() error = %v", err)
+    }
+    m.Set("c", 3)
+    m.Close()
+    want := []string{walName("snapshot", 2, ".smap"), walName("wal", 2, ".log")}
+    if got := walFiles(t, dir); !reflect.DeepEqual(got, want) {
+        t.Errorf("files = %v, want %v", got, want)
+    }
+
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatalf("reopen error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[string]int{"a": 99, "c": 3}) {
+        t.Errorf("All() = %v", all)
+    }
+    if err := NewMap[string, int]().Compact(); err != nil {
+        t.Errorf("Compact() without log error = %v", err)
+    }
+}
+
+func TestOpenMap_version(t *testing.T) {
+    for _, compact := range []bool{false, true} {
+        dir := t.TempDir()
+        m, err := OpenMap[string, int](dir)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for i := 0; i < 5; i++ {
+            m.Set("doc", i)
+        }
+        m.Set("gone", 1)
+        m.Remove("gone")
+        _, version, _, _ := m.GetVersioned("doc")
+        if compact {
+            if err := m.Compact(); err != nil {
+                t.Fatalf("Compact() error = %v", err)
+            }
+        }
+        m.Close()
+
+        got, err := OpenMap[string, int](dir)
+        if err != nil {
+            t.Fatalf("reopen error = %v", err)
+        }
+        if _, v, _, _ := got.GetVersioned("doc"); v != version {
+            t.Errorf("compact=%v GetVersioned() after reopen = %d, want %d", compact, v, version)
+        }
+        if _, err := got.SetIfVersion("doc", 2, version-1); !errors.Is(err, ErrConflict) {
+            t.Errorf("compact=%v SetIfVersion() with stale version error = %v, want %v", compact, err, ErrConflict)
+        }
+        next, err := got.SetIfVersion("doc", 2, version)
+        if err != nil {
+            t.Fatalf("compact=%v SetIfVersion() error = %v", compact, err)
+        }
+        // gone删除前的版本号为version+1，重新创建后的版本号必须更大
+        if v, _ := got.SetIfVersion("gone", 1, 0); v <= next || next <= version+1 {
+            t.Errorf("compact=%v versions after reopen = %d, %d, want greater than %d", compact, next, v, version+1)
+        }
+        got.Close()
+    }
+}
+
+func TestMap_Compact_background(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir, WithCompactSize(256))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 100; i++ {
+        m.Set("a", i)
+    }
+    deadline := time.Now().Add(time.Second)
+    for time.Now().Before(deadline) {
+        if _, err := os.Stat(filepath.Join(dir, walName("wal", 1, ".log"))); os.IsNotExist(err) {
+            break
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+    m.Close()
+    if _, err := os.Stat(filepath.Join(dir, walName("wal", 1, ".log"))); !os.IsNotExist(err) {
+        t.Errorf("background compaction not triggered, files = %v", walFiles(t, dir))
+    }
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatalf("reopen error = %v", err)
+    }
+    defer got.Close()
+    if v, _, _ := got.Get("a"); v != 99 {
+        t.Errorf("Get() = %v, want 99", v)
+    }
+}
+
+func TestOpenMap_interrupted(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    m.Compact()
+    m.Set("b", 2)
+    m.Close()
+    // 模拟压缩中断：未完成的临时快照和已包含在快照中但未删除的旧日志
+    os.WriteFile(filepath.Join(dir, walName("snapshot", 3, ".smap.tmp")), []byte("partial"), 0o644)
+    os.WriteFile(filepath.Join(dir, walName("wal", 1, ".log")), []byte("stale"), 0o644)
+
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatalf("OpenMap() error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[string]int{"a": 1, "b": 2}) {
+        t.Errorf("All() = %v", all)
+    }
+    want := []string{walName("snapshot", 2, ".smap"), walName("wal", 2, ".log"), walName("wal", 3, ".log")}
+    if got := walFiles(t, dir); !reflect.DeepEqual(got, want) {
+        t.Errorf("files = %v, want %v", got, want)
+    }
+}
+
+func TestOpenMap_txnRollback(t *testing.T) {
+    errStore := errors.New("store unavailable")
+    store := newCountingStore()
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir, WithStore[string, int](store, WriteThrough))
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    store.setFail(errStore)
+    err = m.Txn(func(tx *Tx[string, int]) error {
+        tx.Remove("a")
+        return tx.Set("b", 2)
+    })
+    if !errors.Is(err, errStore) {
+        t.Errorf("Txn() error = %v, want %v", err, errStore)
+    }
+    m.Close()
+
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[string]int{"a": 1}) {
+        t.Errorf("All() = %v, want rolled back data", all)
+    }
+}
+
+func TestOpenMap_encodeError(t *testing.T) {
+    type unregistered struct{ A int }
+    dir := t.TempDir()
+    m, err := OpenMapAny(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    if err := m.Set("bad", unregistered{1}); err == nil {
+        t.Errorf("Set() unregistered type error = nil")
+    }
+    if m.Has("bad") {
+        t.Errorf("Has(bad) = true, want unchanged after log error")
+    }
+    if err := m.Set("b", 2); err != nil {
+        t.Errorf("Set() after encode error = %v", err)
+    }
+    m.Close()
+
+    got, err := OpenMapAny(dir)
+    if err != nil {
+        t.Fatalf("reopen error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[interface{}]interface{}{"a": 1, "b": 2}) {
+        t.Errorf("All() = %v", all)
+    }
+}
+
+func TestOpenMap_txnTorn(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir, WithSyncPolicy(SyncNever))
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    m.Set("b", 2)
+    err = m.Txn(func(tx *Tx[string, int]) error {
+        tx.Set("a", 10)
+        tx.Remove("b")
+        return tx.Set("c", 3)
+    })
+    if err != nil {
+        t.Fatalf("Txn() error = %v", err)
+    }
+    m.Close()
+    // 模拟提交过程中崩溃：事务的批量记录只写入了一部分
+    path := filepath.Join(dir, walName("wal", 1, ".log"))
+    data, _ := os.ReadFile(path)
+    os.WriteFile(path, data[:len(data)-5], 0o644)
+
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatalf("OpenMap() error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[string]int{"a": 1, "b": 2}) {
+        t.Errorf("All() = %v, want transaction discarded as a whole", all)
+    }
+}
+
+func TestOpenMap_txnLogError(t *testing.T) {
+    type unregistered struct{ A int }
+    dir := t.TempDir()
+    m, err := OpenMapAny(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    err = m.Txn(func(tx *Tx[interface{}, interface{}]) error {
+        tx.Set("a", 2)
+        tx.Set("b", 2)
+        return tx.Set("bad", unregistered{1})
+    })
+    if err == nil {
+        t.Fatalf("Txn() with unencodable value error = nil")
+    }
+    if all := m.All(); !reflect.DeepEqual(all, map[interface{}]interface{}{"a": 1}) {
+        t.Errorf("All() = %v, want rolled back", all)
+    }
+    m.Set("c", 3)
+    m.Close()
+
+    got, err := OpenMapAny(dir)
+    if err != nil {
+        t.Fatalf("reopen error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[interface{}]interface{}{"a": 1, "c": 3}) {
+        t.Errorf("All() = %v, want failed transaction absent from log", all)
+    }
+}
+
+func TestOpenMap_removeBatch(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenMap[string, int](dir, WithSyncPolicy(SyncNever))
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Set("a", 1)
+    m.Set("b", 2)
+    m.Set("c", 3)
+    m.Remove("a", "b", "missing")
+    m.Close()
+    path := filepath.Join(dir, walName("wal", 1, ".log"))
+    data, _ := os.ReadFile(path)
+    os.WriteFile(path, data[:len(data)-1], 0o644)
+
+    got, err := OpenMap[string, int](dir)
+    if err != nil {
+        t.Fatalf("OpenMap() error = %v", err)
+    }
+    defer got.Close()
+    if all := got.All(); !reflect.DeepEqual(all, map[string]int{"a": 1, "b": 2, "c": 3}) {
+        t.Errorf("All() = %v, want multi-key Remove discarded as a whole", all)
+    }
+}
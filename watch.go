@@ -0,0 +1,329 @@
+package smap
+
+import (
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+// Op 数据变更的操作类型
+type Op int
+
+const (
+    // OpSet 写入或更新key
+    OpSet Op = iota + 1
+    // OpRemove 删除key
+    OpRemove
+    // OpEvict key因容量、成本或过期被淘汰
+    OpEvict
+)
+
+// String 操作类型的名称
+func (o Op) String() string {
+    switch o {
+    case OpSet:
+        return "set"
+    case OpRemove:
+        return "remove"
+    case OpEvict:
+        return "evict"
+    }
+    return "unknown"
+}
+
+// Event 数据变更事件
+type Event[K comparable, V any] struct {
+    Op  Op
+    Key K
+    // Old 变更前的value，HasOld表示变更前key是否存在
+    Old    V
+    HasOld bool
+    // New 变更后的value，仅OpSet有效
+    New V
+    // Reason 淘汰原因，仅OpEvict有效
+    Reason EvictReason
+}
+
+// Overflow 订阅的缓冲区已满时的处理方式
+type Overflow int
+
+const (
+    // OverflowDrop 丢弃新事件，不阻塞写操作
+    OverflowDrop Overflow = iota
+    // OverflowBlock 阻塞写操作直到缓冲区有空位，写操作会持有分片锁等待
+    OverflowBlock
+)
+
+// WatchOption 订阅的配置项
+type WatchOption func(*watchConfig)
+
+// watchConfig 订阅的配置集合
+type watchConfig struct {
+    keys     interface{}
+    prefix   string
+    byPrefix bool
+    buffer   int
+    overflow Overflow
+}
+
+// WatchKeys 只订阅指定key的事件，keys的类型需与Map的key类型一致
+func WatchKeys[K comparable](keys ...K) WatchOption {
+    return func(c *watchConfig) {
+        c.keys = keys
+    }
+}
+
+// WatchPrefix 只订阅以prefix开头的key的事件，只对string类型的key生效
+func WatchPrefix(prefix string) WatchOption {
+    return func(c *watchConfig) {
+        c.prefix = prefix
+        c.byPrefix = true
+    }
+}
+
+// WatchBuffer 设置订阅的缓冲区大小，默认为64
+func WatchBuffer(n int) WatchOption {
+    return func(c *watchConfig) {
+        c.buffer = n
+    }
+}
+
+// WatchOverflow 设置缓冲区已满时的处理方式，默认为OverflowDrop
+func WatchOverflow(overflow Overflow) WatchOption {
+    return func(c *watchConfig) {
+        c.overflow = overflow
+    }
+}
+
+// subscription 一个订阅
+type subscription[K comparable, V any] struct {
+    id       uint64
+    keys     map[K]struct{}
+    prefix   string
+    byPrefix bool
+    block    bool
+    // latest 缓冲区已满时用新事件替换旧事件，要求同一时刻只有一个发送方
+    latest bool
+    ch     chan Event[K, V]
+    done     chan struct{}
+    // mutex 保证关闭ch时没有正在进行的发送
+    mutex   sync.RWMutex
+    closed  bool
+    once    sync.Once
+    dropped atomic.Uint64
+}
+
+// watchers 订阅列表，subs为写时复制的切片，发送事件时无需加锁
+type watchers[K comparable, V any] struct {
+    mutex sync.Mutex
+    next  uint64
+    subs  atomic.Pointer[[]*subscription[K, V]]
+    // holding 为true时事件暂存在held中，事务提交完成后再发送，需持有所有分片写锁
+    holding bool
+    held    []Event[K, V]
+}
+
+// newSubscription 按配置创建订阅
+func newSubscription[K comparable, V any](opts []WatchOption) *subscription[K, V] {
+    c := &watchConfig{buffer: 64}
+    for _, opt := range opts {
+        opt(c)
+    }
+    sub := &subscription[K, V]{
+        prefix:   c.prefix,
+        byPrefix: c.byPrefix,
+        block:    c.overflow == OverflowBlock,
+        ch:       make(chan Event[K, V], max(c.buffer, 0)),
+        done:     make(chan struct{}),
+    }
+    if c.keys != nil {
+        sub.keys = make(map[K]struct{})
+        for _, key := range assertOption[[]K]("watch keys", c.keys) {
+            sub.keys[key] = struct{}{}
+        }
+    }
+    return sub
+}
+
+// match 检查key是否符合订阅的过滤条件
+func (sub *subscription[K, V]) match(key K) bool {
+    if sub.keys != nil {
+        if _, ok := sub.keys[key]; !ok {
+            return false
+        }
+    }
+    if sub.byPrefix {
+        s, ok := any(key).(string)
+        return ok && strings.HasPrefix(s, sub.prefix)
+    }
+    return true
+}
+
+// send 发送事件，缓冲区已满时按配置丢弃或阻塞
+func (sub *subscription[K, V]) send(ev Event[K, V]) {
+    sub.mutex.RLock()
+    defer sub.mutex.RUnlock()
+    if sub.closed {
+        return
+    }
+    if sub.block {
+        select {
+        case sub.ch <- ev:
+        case <-sub.done:
+        }
+        return
+    }
+    select {
+    case sub.ch <- ev:
+        return
+    default:
+    }
+    if sub.latest {
+        select {
+        case <-sub.ch:
+        default:
+        }
+        select {
+        case sub.ch <- ev:
+            return
+        default:
+        }
+    }
+    sub.dropped.Add(1)
+}
+
+// close 关闭订阅，唤醒阻塞中的发送后关闭ch
+func (sub *subscription[K, V]) close() {
+    sub.once.Do(func() {
+        close(sub.done)
+        sub.mutex.Lock()
+        sub.closed = true
+        close(sub.ch)
+        sub.mutex.Unlock()
+    })
+}
+
+// subscribe 添加订阅
+func (a *Map[K, V]) subscribe(sub *subscription[K, V]) uint64 {
+    w := &a.watchers
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    w.next++
+    sub.id = w.next
+    subs := make([]*subscription[K, V], 0)
+    if old := w.subs.Load(); old != nil {
+        subs = append(subs, *old...)
+    }
+    subs = append(subs, sub)
+    w.subs.Store(&subs)
+    return sub.id
+}
+
+// Watch 订阅数据变更事件，返回事件channel和订阅id
+// 事件在持有分片锁时按顺序写入channel，同一分片内的事件保持修改顺序
+// 缓冲区已满时默认丢弃新事件，调用Unsubscribe后channel被关闭
+func (a *Map[K, V]) Watch(opts ...WatchOption) (events <-chan Event[K, V], id uint64) {
+    sub := newSubscription[K, V](opts)
+    return sub.ch, a.subscribe(sub)
+}
+
+// OnChange 订阅数据变更事件并在独立协程中按顺序回调fn，返回订阅id
+// fn内可以调用当前map的任意方法，fn内的panic会被忽略
+func (a *Map[K, V]) OnChange(fn func(ev Event[K, V]), opts ...WatchOption) (id uint64) {
+    sub := newSubscription[K, V](opts)
+    go func() {
+        for ev := range sub.ch {
+            callEventHandler(fn, ev)
+        }
+    }()
+    return a.subscribe(sub)
+}
+
+// callEventHandler 调用事件回调并忽略回调内的panic
+func callEventHandler[K comparable, V any](fn func(ev Event[K, V]), ev Event[K, V]) {
+    defer func() {
+        recover()
+    }()
+    fn(ev)
+}
+
+// Unsubscribe 取消订阅，返回订阅是否存在
+func (a *Map[K, V]) Unsubscribe(id uint64) bool {
+    w := &a.watchers
+    w.mutex.Lock()
+    old := w.subs.Load()
+    if old == nil {
+        w.mutex.Unlock()
+        return false
+    }
+    var found *subscription[K, V]
+    subs := make([]*subscription[K, V], 0, len(*old))
+    for _, sub := range *old {
+        if sub.id == id {
+            found = sub
+            continue
+        }
+        subs = append(subs, sub)
+    }
+    w.subs.Store(&subs)
+    w.mutex.Unlock()
+    if found == nil {
+        return false
+    }
+    found.close()
+    return true
+}
+
+// unsubscribeAll 取消所有订阅
+func (a *Map[K, V]) unsubscribeAll() {
+    w := &a.watchers
+    w.mutex.Lock()
+    old := w.subs.Swap(nil)
+    w.mutex.Unlock()
+    if old == nil {
+        return
+    }
+    for _, sub := range *old {
+        sub.close()
+    }
+}
+
+// watching 检查是否存在订阅
+func (a *Map[K, V]) watching() bool {
+    subs := a.watchers.subs.Load()
+    return subs != nil && len(*subs) > 0
+}
+
+// emit 把事件发送给所有匹配的订阅，需在持有分片写锁时调用
+func (a *Map[K, V]) emit(ev Event[K, V]) {
+    if a.watchers.holding {
+        a.watchers.held = append(a.watchers.held, ev)
+        return
+    }
+    subs := a.watchers.subs.Load()
+    if subs == nil {
+        return
+    }
+    for _, sub := range *subs {
+        if sub.match(ev.Key) {
+            sub.send(ev)
+        }
+    }
+}
+
+// holdEvents 暂存之后产生的事件，需在持有所有分片写锁时调用
+func (a *Map[K, V]) holdEvents() {
+    a.watchers.holding = true
+}
+
+// releaseEvents 停止暂存并发送keep返回true的暂存事件，需在持有所有分片写锁时调用
+func (a *Map[K, V]) releaseEvents(keep func(ev Event[K, V]) bool) {
+    w := &a.watchers
+    held := w.held
+    w.holding, w.held = false, nil
+    for _, ev := range held {
+        if keep(ev) {
+            a.emit(ev)
+        }
+    }
+}
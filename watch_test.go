@@ -0,0 +1,188 @@
+package smap
+
+import (
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+)
+
+// drain 读取channel中已有的所有事件
+func drain[K comparable, V any](ch <-chan Event[K, V]) []Event[K, V] {
+    events := make([]Event[K, V], 0)
+    for {
+        select {
+        case ev := <-ch:
+            events = append(events, ev)
+        default:
+            return events
+        }
+    }
+}
+
+func TestMap_Watch(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {name: "unsafe", opts: nil},
+        {name: "safe", opts: []Option{WithSafe()}},
+        {name: "sharded", opts: []Option{WithShards(4)}},
+        {name: "cow", opts: []Option{WithCopyOnWrite()}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapWith[string, int](tt.opts...)
+            ch, _ := m.Watch()
+            m.Set("a", 1)
+            m.Set("a", 2)
+            m.Remove("a", "b")
+            want := []Event[string, int]{
+                {Op: OpSet, Key: "a", New: 1},
+                {Op: OpSet, Key: "a", Old: 1, HasOld: true, New: 2},
+                {Op: OpRemove, Key: "a", Old: 2, HasOld: true},
+            }
+            if got := drain(ch); !reflect.DeepEqual(got, want) {
+                t.Errorf("Watch() events = %v, want %v", got, want)
+            }
+        })
+    }
+}
+
+func TestMap_WatchFilter(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []WatchOption
+        want []string
+    }{
+        {name: "all", opts: nil, want: []string{"user.1", "order.1", "user.2"}},
+        {name: "keys", opts: []WatchOption{WatchKeys("order.1", "user.2")}, want: []string{"order.1", "user.2"}},
+        {name: "prefix", opts: []WatchOption{WatchPrefix("user.")}, want: []string{"user.1", "user.2"}},
+        {name: "keys and prefix", opts: []WatchOption{WatchKeys("order.1", "user.2"), WatchPrefix("user.")}, want: []string{"user.2"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewMapStrAny()
+            ch, _ := m.Watch(tt.opts...)
+            m.Set("user.1", 1)
+            m.Set("order.1", 1)
+            m.Set("user.2", 1)
+            got := make([]string, 0)
+            for _, ev := range drain(ch) {
+                got = append(got, ev.Key)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Watch() keys = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMap_WatchEvict(t *testing.T) {
+    m := NewMapWith[string, int](WithCapacity(1))
+    ch, _ := m.Watch()
+    m.Set("a", 1)
+    m.Set("b", 2)
+    m.SetWithTTL("c", 3, time.Nanosecond)
+    time.Sleep(time.Millisecond)
+    m.Get("c")
+    want := []Event[string, int]{
+        {Op: OpSet, Key: "a", New: 1},
+        {Op: OpSet, Key: "b", New: 2},
+        {Op: OpEvict, Key: "a", Old: 1, HasOld: true, Reason: EvictCapacity},
+        {Op: OpSet, Key: "c", New: 3},
+        {Op: OpEvict, Key: "b", Old: 2, HasOld: true, Reason: EvictCapacity},
+        {Op: OpEvict, Key: "c", Old: 3, HasOld: true, Reason: EvictExpired},
+    }
+    if got := drain(ch); !reflect.DeepEqual(got, want) {
+        t.Errorf("Watch() events = %v, want %v", got, want)
+    }
+}
+
+func TestMap_WatchOverflow(t *testing.T) {
+    t.Run("drop", func(t *testing.T) {
+        m := NewMap[string, int](true)
+        ch, _ := m.Watch(WatchBuffer(2))
+        for i := 0; i < 5; i++ {
+            m.Set("a", i)
+        }
+        got := drain(ch)
+        if len(got) != 2 || got[0].New != 0 || got[1].New != 1 {
+            t.Errorf("Watch() events = %v, want first 2", got)
+        }
+    })
+    t.Run("block", func(t *testing.T) {
+        m := NewMap[string, int](true)
+        ch, _ := m.Watch(WatchBuffer(1), WatchOverflow(OverflowBlock))
+        done := make(chan struct{})
+        go func() {
+            for i := 0; i < 5; i++ {
+                m.Set("a", i)
+            }
+            close(done)
+        }()
+        for i := 0; i < 5; i++ {
+            if ev := <-ch; ev.New != i {
+                t.Errorf("Watch() event %d = %v", i, ev)
+            }
+        }
+        <-done
+    })
+    t.Run("unsubscribe unblocks", func(t *testing.T) {
+        m := NewMap[string, int](true)
+        _, id := m.Watch(WatchBuffer(0), WatchOverflow(OverflowBlock))
+        done := make(chan struct{})
+        go func() {
+            m.Set("a", 1)
+            close(done)
+        }()
+        time.Sleep(10 * time.Millisecond)
+        m.Unsubscribe(id)
+        <-done
+    })
+}
+
+func TestMap_OnChange(t *testing.T) {
+    m := NewMapStrAny(true)
+    var mutex sync.Mutex
+    got := make([]string, 0)
+    done := make(chan struct{})
+    id := m.OnChange(func(ev Event[string, interface{}]) {
+        mutex.Lock()
+        defer mutex.Unlock()
+        got = append(got, ev.Op.String()+":"+ev.Key)
+        if ev.Key == "panic" {
+            panic("test")
+        }
+        if ev.Op == OpRemove {
+            close(done)
+        }
+    })
+    m.Set("panic", 1)
+    m.Set("a", 1)
+    m.Remove("a")
+    <-done
+    if !m.Unsubscribe(id) {
+        t.Errorf("Unsubscribe() = false, want true")
+    }
+    if m.Unsubscribe(id) {
+        t.Errorf("Unsubscribe() twice = true, want false")
+    }
+    m.Set("b", 1)
+    mutex.Lock()
+    defer mutex.Unlock()
+    want := []string{"set:panic", "set:a", "remove:a"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("OnChange() events = %v, want %v", got, want)
+    }
+}
+
+func TestMap_WatchClose(t *testing.T) {
+    m := NewMap[string, int]()
+    ch, _ := m.Watch()
+    m.Close()
+    if _, ok := <-ch; ok {
+        t.Errorf("Watch() channel not closed after Close")
+    }
+    m.Set("a", 1)
+}